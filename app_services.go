@@ -1,3 +1,6 @@
+//go:build darwin
+// +build darwin
+
 package cf
 
 // #include <ApplicationServices/ApplicationServices.h>
@@ -70,20 +73,63 @@ func CoreDockSetPreferences(values map[string]interface{}) error {
 	return nil
 }
 
-func CoreDockGetOrientationAndPinning() (orientation int, pinning int) {
+// CoreDockCopyDock reads the tiles of the Dock with CoreDockCopyPreferences.
+func CoreDockCopyDock() (*Dock, error) {
+	prefs, err := CoreDockCopyPreferences([]string{"persistent-apps", "persistent-others"})
+	if err != nil {
+		return nil, err
+	}
+	return DecodeDock(prefs)
+}
+
+// CoreDockSetDock replaces the tiles of the Dock with CoreDockSetPreferences.
+func CoreDockSetDock(dock *Dock) error {
+	return CoreDockSetPreferences(map[string]interface{}{
+		"persistent-apps":   encodeDockTileArray(dock.Apps),
+		"persistent-others": encodeDockTileArray(dock.Others),
+	})
+}
+
+// CoreDockGetOrientationAndPinning returns where the Dock is, or an error if
+// CoreDock returns codes that are not Orientation and Pinning constants.
+func CoreDockGetOrientationAndPinning() (Orientation, Pinning, error) {
 	var ori, pi C.int
 	C.CoreDockGetOrientationAndPinning(&ori, &pi)
-	return int(ori), int(pi)
+	orientation, pinning := Orientation(ori), Pinning(pi)
+	if err := orientation.Validate(); err != nil {
+		return 0, 0, err
+	}
+	if err := pinning.Validate(); err != nil {
+		return 0, 0, err
+	}
+	return orientation, pinning, nil
 }
 
-func CoreDockSetOrientationAndPinning(orientation, pinning int) {
+// CoreDockSetOrientationAndPinning moves the Dock, after checking that both
+// values are valid.
+func CoreDockSetOrientationAndPinning(orientation Orientation, pinning Pinning) error {
+	if err := orientation.Validate(); err != nil {
+		return err
+	}
+	if err := pinning.Validate(); err != nil {
+		return err
+	}
 	C.CoreDockSetOrientationAndPinning(C.int(orientation), C.int(pinning))
+	return nil
 }
 
+// CoreDockGetTileSize returns the size of icons scaled to 0–1; DockTileSize
+// converts it to points.
 func CoreDockGetTileSize() float64 {
 	return float64(C.CoreDockGetTileSize())
 }
 
-func CoreDockSetTileSize(size float64) {
-	C.CoreDockSetTileSize(C.float(size))
+// CoreDockSetTileSize sets the size of icons scaled to 0–1, as
+// DockSettings.NormalizedTileSize returns it.
+func CoreDockSetTileSize(normalized float64) error {
+	if _, err := DockTileSize(normalized); err != nil {
+		return err
+	}
+	C.CoreDockSetTileSize(C.float(normalized))
+	return nil
 }
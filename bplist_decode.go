@@ -0,0 +1,428 @@
+package cf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+// Binary property list format ("bplist00"), as written by CFPropertyListCreateData
+// with kCFPropertyListBinaryFormat_v1_0.
+//
+// A file is a header, a sequence of objects, an offset table and a trailer.
+// Every object starts with a marker byte: the high nibble is the object type and
+// the low nibble is either a size or a length (0xF meaning "length follows as an
+// integer object").
+
+const bplistMagic = "bplist00"
+
+const bplistTrailerSize = 32
+
+// CFAbsoluteTime epoch, 2001-01-01T00:00:00Z, in Unix seconds
+const absoluteTimeIntervalSince1970 = 978307200
+
+const (
+	bplistNull    = 0x00
+	bplistFalse   = 0x08
+	bplistTrue    = 0x09
+	bplistFill    = 0x0f
+	bplistInt     = 0x10
+	bplistReal    = 0x20
+	bplistDate    = 0x33
+	bplistData    = 0x40
+	bplistASCII   = 0x50
+	bplistUnicode = 0x60
+	bplistUID     = 0x80
+	bplistArray   = 0xa0
+	bplistSet     = 0xc0
+	bplistDict    = 0xd0
+)
+
+type bplistTrailer struct {
+	OffsetIntSize     uint8
+	ObjectRefSize     uint8
+	NumObjects        uint64
+	TopObject         uint64
+	OffsetTableOffset uint64
+}
+
+// InvalidBinaryPlistError is returned when binary property list data is malformed.
+type InvalidBinaryPlistError struct {
+	Offset int
+	Reason string
+}
+
+func (e *InvalidBinaryPlistError) Error() string {
+	return fmt.Sprintf("plist: invalid binary plist at offset %d: %s", e.Offset, e.Reason)
+}
+
+type bplistDecoder struct {
+	data    []byte
+	trailer bplistTrailer
+	offsets []uint64
+	// objects currently being decoded, to detect reference cycles
+	active map[uint64]bool
+}
+
+// DecodeBinaryPlist decodes a binary property list into Go values.
+//
+// The result uses exactly the types typeRef.Goize produces for the same data
+// after CFPropertyListCreateWithData: string, int64, float32 (for 4-byte reals),
+// float64, bool, []byte, time.Time (local, millisecond precision),
+// []interface{} (nil when empty) and map[string]interface{}, plus uint64 for
+// integers above the int64 range and UID for keyed archiver references.
+func DecodeBinaryPlist(data []byte) (interface{}, error) {
+	v, err := DecodeBinaryPlistValue(data)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// DecodeBinaryPlistValue decodes a binary property list into a Value, keeping
+// the width of reals, full date precision and dictionary key order.
+func DecodeBinaryPlistValue(data []byte) (Value, error) {
+	d := &bplistDecoder{data: data, active: map[uint64]bool{}}
+	if err := d.readTrailer(); err != nil {
+		return nil, err
+	}
+	return d.object(d.trailer.TopObject)
+}
+
+func (d *bplistDecoder) fail(offset uint64, format string, args ...interface{}) error {
+	return &InvalidBinaryPlistError{Offset: int(offset), Reason: fmt.Sprintf(format, args...)}
+}
+
+func (d *bplistDecoder) readTrailer() error {
+	if len(d.data) < len(bplistMagic)+bplistTrailerSize+1 {
+		return d.fail(0, "file is too short")
+	}
+	if !bytes.HasPrefix(d.data, []byte(bplistMagic)) {
+		return d.fail(0, "missing %q header", bplistMagic)
+	}
+
+	trailerStart := uint64(len(d.data) - bplistTrailerSize)
+	t := d.data[trailerStart:]
+	d.trailer = bplistTrailer{
+		OffsetIntSize:     t[6],
+		ObjectRefSize:     t[7],
+		NumObjects:        binary.BigEndian.Uint64(t[8:]),
+		TopObject:         binary.BigEndian.Uint64(t[16:]),
+		OffsetTableOffset: binary.BigEndian.Uint64(t[24:]),
+	}
+	tr := d.trailer
+
+	if !validIntSize(tr.OffsetIntSize) {
+		return d.fail(trailerStart, "invalid offset size %d", tr.OffsetIntSize)
+	}
+	if !validIntSize(tr.ObjectRefSize) {
+		return d.fail(trailerStart, "invalid object reference size %d", tr.ObjectRefSize)
+	}
+	if tr.NumObjects == 0 {
+		return d.fail(trailerStart, "no objects")
+	}
+	if tr.TopObject >= tr.NumObjects {
+		return d.fail(trailerStart, "top object %d out of range", tr.TopObject)
+	}
+	if tr.OffsetTableOffset < uint64(len(bplistMagic)) || tr.OffsetTableOffset > trailerStart {
+		return d.fail(trailerStart, "offset table offset %d out of range", tr.OffsetTableOffset)
+	}
+	if (trailerStart-tr.OffsetTableOffset)/uint64(tr.OffsetIntSize) < tr.NumObjects {
+		return d.fail(tr.OffsetTableOffset, "offset table is truncated")
+	}
+
+	d.offsets = make([]uint64, tr.NumObjects)
+	for i := range d.offsets {
+		pos := tr.OffsetTableOffset + uint64(i)*uint64(tr.OffsetIntSize)
+		off := readSizedUint(d.data[pos:], tr.OffsetIntSize)
+		if off < uint64(len(bplistMagic)) || off >= tr.OffsetTableOffset {
+			return d.fail(pos, "object %d offset %d out of range", i, off)
+		}
+		d.offsets[i] = off
+	}
+	return nil
+}
+
+func validIntSize(n uint8) bool {
+	return n == 1 || n == 2 || n == 4 || n == 8
+}
+
+// readSizedUint reads a big-endian unsigned integer of n bytes
+func readSizedUint(b []byte, n uint8) uint64 {
+	var v uint64
+	for i := uint8(0); i < n; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+// bytesAt returns n bytes of object data starting at off
+func (d *bplistDecoder) bytesAt(off, n uint64) ([]byte, error) {
+	end := d.trailer.OffsetTableOffset
+	if off > end || n > end-off {
+		return nil, d.fail(off, "object data runs past the end of the object area")
+	}
+	return d.data[off : off+n], nil
+}
+
+// length decodes the length of a variable-length object, returning the length
+// and the offset of the object's payload.
+func (d *bplistDecoder) length(off uint64) (uint64, uint64, error) {
+	marker := d.data[off]
+	if marker&0x0f != 0x0f {
+		return uint64(marker & 0x0f), off + 1, nil
+	}
+	hdr, err := d.bytesAt(off+1, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if hdr[0]&0xf0 != bplistInt {
+		return 0, 0, d.fail(off+1, "length is not an integer (marker 0x%02x)", hdr[0])
+	}
+	size := uint64(1) << (hdr[0] & 0x0f)
+	if size > 8 {
+		return 0, 0, d.fail(off+1, "length integer is too wide (%d bytes)", size)
+	}
+	b, err := d.bytesAt(off+2, size)
+	if err != nil {
+		return 0, 0, err
+	}
+	n := readSizedUint(b, uint8(size))
+	if n > d.trailer.OffsetTableOffset {
+		return 0, 0, d.fail(off, "length %d is too large", n)
+	}
+	return n, off + 2 + size, nil
+}
+
+func (d *bplistDecoder) refs(off, count uint64) ([]uint64, error) {
+	size := uint64(d.trailer.ObjectRefSize)
+	if count > d.trailer.OffsetTableOffset/size {
+		return nil, d.fail(off, "too many object references")
+	}
+	b, err := d.bytesAt(off, count*size)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]uint64, count)
+	for i := range refs {
+		refs[i] = readSizedUint(b[uint64(i)*size:], d.trailer.ObjectRefSize)
+		if refs[i] >= d.trailer.NumObjects {
+			return nil, d.fail(off+uint64(i)*size, "object reference %d out of range", refs[i])
+		}
+	}
+	return refs, nil
+}
+
+func (d *bplistDecoder) object(ref uint64) (Value, error) {
+	if d.active[ref] {
+		return nil, d.fail(d.offsets[ref], "object %d references itself", ref)
+	}
+	d.active[ref] = true
+	defer delete(d.active, ref)
+
+	off := d.offsets[ref]
+	marker := d.data[off]
+	switch marker & 0xf0 {
+	case 0x00:
+		switch marker {
+		case bplistFalse:
+			return Bool(false), nil
+		case bplistTrue:
+			return Bool(true), nil
+		}
+	case bplistInt:
+		return d.integer(off)
+	case bplistReal:
+		return d.real(off)
+	case bplistDate & 0xf0:
+		if marker != bplistDate {
+			break
+		}
+		b, err := d.bytesAt(off+1, 8)
+		if err != nil {
+			return nil, err
+		}
+		return dateFromAbsoluteTime(math.Float64frombits(binary.BigEndian.Uint64(b))), nil
+	case bplistData:
+		n, start, err := d.length(off)
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.bytesAt(start, n)
+		if err != nil {
+			return nil, err
+		}
+		return append(Data{}, b...), nil
+	case bplistASCII:
+		n, start, err := d.length(off)
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.bytesAt(start, n)
+		if err != nil {
+			return nil, err
+		}
+		return String(asciiString(b)), nil
+	case bplistUnicode:
+		n, start, err := d.length(off)
+		if err != nil {
+			return nil, err
+		}
+		if n > math.MaxInt64/2 {
+			return nil, d.fail(off, "string is too long")
+		}
+		b, err := d.bytesAt(start, n*2)
+		if err != nil {
+			return nil, err
+		}
+		return String(utf16BEString(b)), nil
+	case bplistUID:
+		size := uint64(marker&0x0f) + 1
+		if size > 8 {
+			return nil, d.fail(off, "UID is too wide (%d bytes)", size)
+		}
+		b, err := d.bytesAt(off+1, size)
+		if err != nil {
+			return nil, err
+		}
+		return UID(readSizedUint(b, uint8(size))), nil
+	case bplistArray:
+		return d.array(off)
+	case bplistDict:
+		return d.dict(off)
+	case bplistSet:
+		return nil, d.fail(off, "set objects are not supported")
+	}
+	return nil, d.fail(off, "unknown object marker 0x%02x", marker)
+}
+
+func (d *bplistDecoder) integer(off uint64) (Integer, error) {
+	size := uint64(1) << (d.data[off] & 0x0f)
+	if size > 16 {
+		return Integer{}, d.fail(off, "invalid integer size %d", size)
+	}
+	b, err := d.bytesAt(off+1, size)
+	if err != nil {
+		return Integer{}, err
+	}
+	switch size {
+	case 1, 2, 4:
+		// 1, 2 and 4-byte integers are unsigned; CoreFoundation reads all
+		// integers up to 8 bytes as SInt64
+		return Int(int64(readSizedUint(b, uint8(size)))), nil
+	case 8:
+		return Int(int64(binary.BigEndian.Uint64(b))), nil
+	default:
+		// 16-byte integers only exist to carry values outside of the int64 range
+		hi, lo := int64(binary.BigEndian.Uint64(b)), binary.BigEndian.Uint64(b[8:])
+		switch {
+		case hi == 0:
+			return Integer{Value: lo, Width: 16}, nil
+		case hi == -1 && lo > math.MaxInt64:
+			return Integer{Value: lo, Signed: true, Width: 16}, nil
+		}
+		return Integer{}, d.fail(off, "integer does not fit in 64 bits")
+	}
+}
+
+func (d *bplistDecoder) real(off uint64) (Real, error) {
+	switch d.data[off] & 0x0f {
+	case 2:
+		b, err := d.bytesAt(off+1, 4)
+		if err != nil {
+			return Real{}, err
+		}
+		return Real{float64(math.Float32frombits(binary.BigEndian.Uint32(b))), 4}, nil
+	case 3:
+		b, err := d.bytesAt(off+1, 8)
+		if err != nil {
+			return Real{}, err
+		}
+		return Real{math.Float64frombits(binary.BigEndian.Uint64(b)), 8}, nil
+	}
+	return Real{}, d.fail(off, "invalid real size marker 0x%02x", d.data[off])
+}
+
+func (d *bplistDecoder) array(off uint64) (Array, error) {
+	n, start, err := d.length(off)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := d.refs(start, n)
+	if err != nil {
+		return nil, err
+	}
+	out := make(Array, len(refs))
+	for i, ref := range refs {
+		if out[i], err = d.object(ref); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (d *bplistDecoder) dict(off uint64) (Dict, error) {
+	n, start, err := d.length(off)
+	if err != nil {
+		return nil, err
+	}
+	if n > math.MaxInt64/2 {
+		return nil, d.fail(off, "dictionary is too large")
+	}
+	refs, err := d.refs(start, n*2)
+	if err != nil {
+		return nil, err
+	}
+	out := make(Dict, 0, n)
+	for i := uint64(0); i < n; i++ {
+		key, err := d.object(refs[i])
+		if err != nil {
+			return nil, err
+		}
+		skey, ok := key.(String)
+		if !ok {
+			return nil, d.fail(d.offsets[refs[i]], "dictionary key is %T, not a string", key)
+		}
+		val, err := d.object(refs[n+i])
+		if err != nil {
+			return nil, err
+		}
+		out.Set(string(skey), val)
+	}
+	return out, nil
+}
+
+// goizeAbsoluteTime converts CFAbsoluteTime to time.Time the way dateRef.Goize does
+func goizeAbsoluteTime(abs float64) time.Time {
+	unix := abs + absoluteTimeIntervalSince1970
+	// pull out milliseconds, to get a more predictable conversion
+	ms := int64(math.Round(unix * 1000))
+	sec := ms / 1000
+	nsec := (ms % 1000) * int64(time.Millisecond)
+	return time.Unix(sec, nsec)
+}
+
+func asciiString(b []byte) string {
+	for _, c := range b {
+		if c >= 0x80 {
+			// not really ASCII: treat it as Latin-1, as CoreFoundation does
+			runes := make([]rune, len(b))
+			for i, c := range b {
+				runes[i] = rune(c)
+			}
+			return string(runes)
+		}
+	}
+	return string(b)
+}
+
+func utf16BEString(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,240 @@
+package cf
+
+import (
+	"encoding/binary"
+	"math"
+	"unicode/utf16"
+)
+
+// bplistObject is a flattened object: either fully encoded scalar data or a
+// container whose object references are written once the reference size is known
+type bplistObject struct {
+	marker byte
+	data   []byte
+	refs   []uint64
+}
+
+// bplistKey identifies a scalar for uniquing
+type bplistKey struct {
+	marker byte
+	value  string
+}
+
+type bplistEncoder struct {
+	objects []bplistObject
+	unique  map[bplistKey]uint64
+}
+
+// EncodeBinaryPlist encodes v as a binary property list.
+//
+// v may be any value Pool.Object accepts, and may contain Values. Repeated
+// strings, numbers, dates and data are stored once, dictionary keys are
+// written in sorted order (or in Dict order) and offsets and object references
+// use the smallest sufficient width, as CFPropertyListCreateData does.
+func EncodeBinaryPlist(v interface{}) ([]byte, error) {
+	val, err := objectValue(v)
+	if err != nil {
+		return nil, err
+	}
+	e := &bplistEncoder{unique: map[bplistKey]uint64{}}
+	e.flatten(val)
+	return e.write(), nil
+}
+
+func (e *bplistEncoder) add(o bplistObject) uint64 {
+	e.objects = append(e.objects, o)
+	return uint64(len(e.objects) - 1)
+}
+
+func (e *bplistEncoder) addScalar(marker byte, data []byte) uint64 {
+	key := bplistKey{marker, string(data)}
+	if ref, ok := e.unique[key]; ok {
+		return ref
+	}
+	ref := e.add(bplistObject{marker: marker, data: data})
+	e.unique[key] = ref
+	return ref
+}
+
+// flatten appends val and everything it contains to the object list, parents
+// before children and dictionary keys before values, and returns val's reference
+func (e *bplistEncoder) flatten(val Value) uint64 {
+	switch v := val.(type) {
+	case Bool:
+		if v {
+			return e.addScalar(bplistTrue, nil)
+		}
+		return e.addScalar(bplistFalse, nil)
+	case Integer:
+		return e.addScalar(bplistInt, bplistIntegerBytes(v))
+	case Real:
+		if v.Width == 4 {
+			b := make([]byte, 4)
+			binary.BigEndian.PutUint32(b, math.Float32bits(float32(v.Value)))
+			return e.addScalar(bplistReal|2, b)
+		}
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, math.Float64bits(v.Value))
+		return e.addScalar(bplistReal|3, b)
+	case Date:
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, math.Float64bits(v.absoluteTime()))
+		return e.addScalar(bplistDate, b)
+	case Data:
+		return e.addScalar(bplistData, v)
+	case String:
+		if isASCII(string(v)) {
+			return e.addScalar(bplistASCII, []byte(v))
+		}
+		units := utf16.Encode([]rune(string(v)))
+		b := make([]byte, 2*len(units))
+		for i, u := range units {
+			binary.BigEndian.PutUint16(b[2*i:], u)
+		}
+		return e.addScalar(bplistUnicode, b)
+	case UID:
+		b := bplistUintBytes(uint64(v))
+		b[0] = bplistUID | byte(len(b)-2)
+		return e.addScalar(bplistUID, b)
+	case Array:
+		// containers are never uniqued
+		ref := e.add(bplistObject{marker: bplistArray})
+		refs := make([]uint64, len(v))
+		for i, elem := range v {
+			refs[i] = e.flatten(elem)
+		}
+		e.objects[ref].refs = refs
+		return ref
+	case Dict:
+		ref := e.add(bplistObject{marker: bplistDict})
+		refs := make([]uint64, 2*len(v))
+		for i, entry := range v {
+			refs[i] = e.flatten(String(entry.Key))
+		}
+		for i, entry := range v {
+			refs[len(v)+i] = e.flatten(entry.Value)
+		}
+		e.objects[ref].refs = refs
+		return ref
+	}
+	panic("plist: unexpected Value type")
+}
+
+func (e *bplistEncoder) write() []byte {
+	refSize := bplistUintSize(uint64(len(e.objects)) - 1)
+
+	out := []byte(bplistMagic)
+	offsets := make([]uint64, len(e.objects))
+	for i, o := range e.objects {
+		offsets[i] = uint64(len(out))
+		switch o.marker {
+		case bplistFalse, bplistTrue, bplistReal | 2, bplistReal | 3, bplistDate:
+			out = append(out, o.marker)
+			out = append(out, o.data...)
+		case bplistInt, bplistUID:
+			out = append(out, o.data...)
+		case bplistData, bplistASCII:
+			out = appendBplistLength(out, o.marker, len(o.data))
+			out = append(out, o.data...)
+		case bplistUnicode:
+			out = appendBplistLength(out, o.marker, len(o.data)/2)
+			out = append(out, o.data...)
+		case bplistArray:
+			out = appendBplistLength(out, o.marker, len(o.refs))
+			out = appendBplistRefs(out, o.refs, refSize)
+		case bplistDict:
+			out = appendBplistLength(out, o.marker, len(o.refs)/2)
+			out = appendBplistRefs(out, o.refs, refSize)
+		}
+	}
+
+	offsetTableOffset := uint64(len(out))
+	offsetSize := bplistUintSize(offsetTableOffset)
+	out = appendBplistRefs(out, offsets, offsetSize)
+
+	trailer := make([]byte, bplistTrailerSize)
+	trailer[6] = offsetSize
+	trailer[7] = refSize
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(e.objects)))
+	binary.BigEndian.PutUint64(trailer[16:], 0)
+	binary.BigEndian.PutUint64(trailer[24:], offsetTableOffset)
+	return append(out, trailer...)
+}
+
+// bplistIntegerBytes encodes an integer object, marker included. Non-negative
+// values use the smallest of 1, 2, 4 or 8 bytes, negative values always use 8
+// and values above the int64 range use 16.
+func bplistIntegerBytes(i Integer) []byte {
+	if i.Signed && int64(i.Value) < 0 {
+		b := make([]byte, 9)
+		b[0] = bplistInt | 3
+		binary.BigEndian.PutUint64(b[1:], i.Value)
+		return b
+	}
+	if i.Value > math.MaxInt64 {
+		b := make([]byte, 17)
+		b[0] = bplistInt | 4
+		binary.BigEndian.PutUint64(b[9:], i.Value)
+		return b
+	}
+	return bplistUintBytes(i.Value)
+}
+
+func bplistUintBytes(u uint64) []byte {
+	size := bplistUintSize(u)
+	b := make([]byte, 1+size)
+	switch size {
+	case 1:
+		b[0] = bplistInt
+		b[1] = byte(u)
+	case 2:
+		b[0] = bplistInt | 1
+		binary.BigEndian.PutUint16(b[1:], uint16(u))
+	case 4:
+		b[0] = bplistInt | 2
+		binary.BigEndian.PutUint32(b[1:], uint32(u))
+	default:
+		b[0] = bplistInt | 3
+		binary.BigEndian.PutUint64(b[1:], u)
+	}
+	return b
+}
+
+// bplistUintSize returns the number of bytes needed to store u
+func bplistUintSize(u uint64) uint8 {
+	switch {
+	case u <= math.MaxUint8:
+		return 1
+	case u <= math.MaxUint16:
+		return 2
+	case u <= math.MaxUint32:
+		return 4
+	}
+	return 8
+}
+
+func appendBplistLength(out []byte, marker byte, n int) []byte {
+	if n < 0x0f {
+		return append(out, marker|byte(n))
+	}
+	out = append(out, marker|0x0f)
+	return append(out, bplistUintBytes(uint64(n))...)
+}
+
+func appendBplistRefs(out []byte, refs []uint64, size uint8) []byte {
+	for _, ref := range refs {
+		for i := int(size) - 1; i >= 0; i-- {
+			out = append(out, byte(ref>>(8*uint(i))))
+		}
+	}
+	return out
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
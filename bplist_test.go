@@ -0,0 +1,127 @@
+package cf
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// generated by Python's plistlib, which mirrors CFBinaryPList.c
+const bplistFixture = "62706c6973743030dd0102030405060708090a0b0c0d0e0f10111213161718191a1b1c536269675464617461" +
+	"546461746555656d70747953696e74546c697374546e616d65536e6567566e6573746564526e6f547265616c57756e69636f" +
+	"646553796573130000010000000000430001023341c11674dfa00000a0102aa3141514516110015568656c6c6f13ffffffff" +
+	"fffffff9d008233ff800000000000067006800e9006c006c006f00202603090823272c31373b40454950535860646d717a7b" +
+	"7d8183858b9495969fae0000000000000101000000000000001d000000000000000000000000000000af"
+
+func mustHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+func TestDecodeBinaryPlist(t *testing.T) {
+	v, err := DecodeBinaryPlist(mustHex(t, bplistFixture))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"name":    "hello",
+		"unicode": "héllo ☃",
+		"int":     int64(42),
+		"neg":     int64(-7),
+		"big":     int64(1 << 40),
+		"real":    1.5,
+		"yes":     true,
+		"no":      false,
+		"date":    time.Unix(1551675967, 250*int64(time.Millisecond)),
+		"data":    []byte{0, 1, 2},
+		"list":    []interface{}{"a", int64(1), "a"},
+		"empty":   []interface{}(nil),
+		"nested":  map[string]interface{}{},
+	}, v)
+}
+
+func TestDecodeBinaryPlistFloat32(t *testing.T) {
+	// a single 4-byte real, 1.5
+	data := mustHex(t, "62706c6973743030"+"223fc00000"+"08"+
+		"000000000000"+"0101"+"0000000000000001"+"0000000000000000"+"000000000000000d")
+	v, err := DecodeBinaryPlist(data)
+	require.NoError(t, err)
+	require.Equal(t, float32(1.5), v)
+}
+
+func TestDecodeBinaryPlistInvalid(t *testing.T) {
+	fixture := mustHex(t, bplistFixture)
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"magic":     append([]byte("bplist01"), fixture[8:]...),
+		"truncated": fixture[:len(fixture)-40],
+		// an array containing itself
+		"cycle": mustHex(t, "62706c6973743030"+"a100"+"08"+
+			"000000000000"+"0101"+"0000000000000001"+"0000000000000000"+"000000000000000a"),
+		// a string claiming to be longer than the file
+		"length": mustHex(t, "62706c6973743030"+"5f107f41"+"08"+
+			"000000000000"+"0101"+"0000000000000001"+"0000000000000000"+"000000000000000c"),
+		// 2^64, which needs more than 64 bits
+		"int128": mustHex(t, "62706c6973743030"+"14"+"0000000000000001"+"0000000000000000"+"08"+
+			"000000000000"+"0101"+"0000000000000001"+"0000000000000000"+"0000000000000019"),
+	} {
+		_, err := DecodeBinaryPlist(data)
+		require.IsType(t, &InvalidBinaryPlistError{}, err, name)
+	}
+}
+
+func TestEncodeBinaryPlist(t *testing.T) {
+	data, err := EncodeBinaryPlist(map[string]interface{}{
+		"name":    "hello",
+		"unicode": "héllo ☃",
+		"int":     42,
+		"neg":     int8(-7),
+		"big":     int64(1 << 40),
+		"real":    1.5,
+		"yes":     true,
+		"no":      false,
+		"date":    time.Unix(1551675967, 250*int64(time.Millisecond)),
+		"data":    []byte{0, 1, 2},
+		"list":    []interface{}{"a", uint16(1), "a"},
+		"empty":   []string{},
+		"nested":  map[string]int{},
+	})
+	require.NoError(t, err)
+	require.Equal(t, bplistFixture, hex.EncodeToString(data))
+}
+
+func TestEncodeBinaryPlistUnsupported(t *testing.T) {
+	_, err := EncodeBinaryPlist(map[string]interface{}{"a": make(chan int)})
+	require.IsType(t, &UnsupportedTypeError{}, err)
+	_, err = EncodeBinaryPlist([]interface{}{nil})
+	require.IsType(t, &UnsupportedValueError{}, err)
+	_, err = EncodeBinaryPlist(map[int]string{})
+	require.IsType(t, &UnsupportedTypeError{}, err)
+}
+
+func TestBinaryPlistUint64(t *testing.T) {
+	in := []interface{}{uint64(math.MaxUint64), uint64(1 << 63), uint(5), int64(math.MinInt64)}
+	data, err := EncodeBinaryPlist(in)
+	require.NoError(t, err)
+	val, err := DecodeBinaryPlist(data)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{uint64(math.MaxUint64), uint64(1 << 63), int64(5), int64(math.MinInt64)}, val)
+}
+
+func TestBinaryPlistArbitrary(t *testing.T) {
+	f := func(arb Arbitrary) interface{} { a, _ := standardize(arb.Value); return a }
+	g := func(arb Arbitrary) interface{} {
+		data, err := EncodeBinaryPlist(arb.Value)
+		require.NoError(t, err)
+		val, err := DecodeBinaryPlist(data)
+		require.NoError(t, err)
+		a, _ := standardize(val)
+		return a
+	}
+	if err := quick.CheckEqual(f, g, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,327 @@
+// Command dockutil edits the Dock with the flags of dockutil
+// (https://github.com/kcrawford/dockutil):
+//
+//	dockutil --add <path|url> [--label <label>] [--replacing <label>]
+//	         [--position beginning|end|middle|<index>] [--before <label>] [--after <label>]
+//	         [--section apps|others] [--display folder|stack] [--view grid|fan|list|auto]
+//	         [--sort name|dateadded|datemodified|datecreated|kind] [--no-restart]
+//	dockutil --add '' --type spacer|small-spacer|flex-spacer [--section apps|others] [--position ...]
+//	dockutil --remove <label|bundle id|path|url|all> [--section apps|others] [--no-restart]
+//	dockutil --move <label> --position ...|--before <label>|--after <label> [--no-restart]
+//	dockutil --find <label>
+//	dockutil --list
+//	dockutil --dedupe [--no-restart]
+//
+// Labels may also be bundle identifiers, paths or URLs. The Dock is only
+// written, and restarted, if it changes.
+//
+// Every command takes an optional last argument, a com.apple.dock.plist file
+// or a home directory, to edit offline instead of the Dock of the current
+// user. The file keeps its format, binary or XML.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	cf "github.com/dottedmag/go-cf"
+)
+
+type options struct {
+	add, replacing, remove, move, find string
+	list, dedupe, noRestart, version   bool
+
+	label, position, before, after, section string
+	tileType, display, view, sort           string
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.add, "add", "", "add the application, folder, file or URL")
+	flag.StringVar(&o.replacing, "replacing", "", "replace the tile with this label instead of adding")
+	flag.StringVar(&o.remove, "remove", "", `remove the tiles with this label, or "all"`)
+	flag.StringVar(&o.move, "move", "", "move the tile with this label")
+	flag.StringVar(&o.find, "find", "", "find the tile with this label")
+	flag.BoolVar(&o.list, "list", false, "list the tiles")
+	flag.BoolVar(&o.dedupe, "dedupe", false, "remove duplicate tiles")
+	flag.BoolVar(&o.noRestart, "no-restart", false, "do not restart the Dock")
+	flag.BoolVar(&o.version, "version", false, "print the version")
+	flag.StringVar(&o.label, "label", "", "label of the added tile")
+	flag.StringVar(&o.position, "position", "", "beginning, end, middle or a 1-based index")
+	flag.StringVar(&o.before, "before", "", "place the tile before the tile with this label")
+	flag.StringVar(&o.after, "after", "", "place the tile after the tile with this label")
+	flag.StringVar(&o.section, "section", "", "apps or others")
+	flag.StringVar(&o.tileType, "type", "", "spacer, small-spacer or flex-spacer")
+	flag.StringVar(&o.display, "display", "stack", "folder or stack")
+	flag.StringVar(&o.view, "view", "auto", "grid, fan, list or auto")
+	flag.StringVar(&o.sort, "sort", "name", "name, dateadded, datemodified, datecreated or kind")
+	flag.Parse()
+
+	if o.version {
+		fmt.Println("dockutil (go-cf)")
+		return
+	}
+	if flag.NArg() > 1 {
+		fail(fmt.Errorf("unexpected argument %q", flag.Arg(1)))
+	}
+	if err := run(o, flag.Arg(0)); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "dockutil:", err)
+	os.Exit(1)
+}
+
+// run edits the Dock of the current user, or the file at path if it is not
+// empty
+func run(o options, path string) error {
+	var dock *cf.Dock
+	var file *cf.DockFile
+	var prefs map[string]interface{}
+	var err error
+	if path != "" {
+		if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+			path = filepath.Join(path, filepath.FromSlash(cf.DockFilePath))
+		}
+		if file, err = cf.OpenDockFile(path); err != nil {
+			return err
+		}
+		dock = file.Dock
+	} else {
+		if prefs, err = cf.DockDomain.Values(); err != nil {
+			return err
+		}
+		if dock, err = cf.DecodeDock(prefs); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case o.list:
+		list(dock)
+		return nil
+	case o.find != "":
+		section, i, ok := dock.Find(o.find)
+		if !ok {
+			fmt.Printf("%s was not found in Dock\n", o.find)
+			os.Exit(1)
+		}
+		fmt.Printf("%s was found in persistent-%s at slot %d\n", o.find, section, i+1)
+		return nil
+	}
+
+	changed, err := edit(dock, o)
+	if err != nil {
+		return err
+	}
+	if changed && file != nil {
+		changed, err = file.Save()
+		if err != nil {
+			return err
+		}
+	}
+	if !changed {
+		fmt.Println("Dock is unchanged")
+		return nil
+	}
+	if file != nil {
+		return nil
+	}
+	return save(dock, prefs, o.noRestart)
+}
+
+// edit applies the operation the flags ask for and reports whether the Dock
+// changed
+func edit(dock *cf.Dock, o options) (bool, error) {
+	switch {
+	case o.add != "" || o.tileType != "":
+		tile, err := newTile(o)
+		if err != nil {
+			return false, err
+		}
+		if o.replacing != "" {
+			if _, _, ok := dock.Find(o.replacing); ok {
+				return dock.Replace(o.replacing, tile)
+			}
+		}
+		section := cf.DockSection(o.section)
+		if section == "" {
+			section = cf.DockApps
+			switch tile.(type) {
+			case *cf.FolderTile, *cf.URLTile:
+				section = cf.DockOthers
+			}
+		}
+		pos, err := position(dock, section, o)
+		if err != nil {
+			return false, err
+		}
+		changed, err := dock.Add(section, tile, pos)
+		if err == nil && !changed {
+			fmt.Printf("%s already exists in Dock\n", o.add)
+		}
+		return changed, err
+
+	case o.remove == "all":
+		sections := []cf.DockSection{cf.DockApps, cf.DockOthers}
+		if o.section != "" {
+			sections = []cf.DockSection{cf.DockSection(o.section)}
+		}
+		changed := false
+		for _, s := range sections {
+			removed, err := dock.RemoveAll(s)
+			if err != nil {
+				return false, err
+			}
+			changed = changed || removed
+		}
+		return changed, nil
+
+	case o.remove != "":
+		if o.section != "" {
+			removed, err := dock.RemoveFrom(cf.DockSection(o.section), o.remove)
+			return removed > 0, err
+		}
+		return dock.Remove(o.remove) > 0, nil
+
+	case o.move != "":
+		section, _, ok := dock.Find(o.move)
+		if !ok {
+			return false, &cf.DockTileNotFoundError{Name: o.move}
+		}
+		pos, err := position(dock, section, o)
+		if err != nil {
+			return false, err
+		}
+		return dock.Move(o.move, pos)
+
+	case o.dedupe:
+		return dock.Dedupe() > 0, nil
+	}
+	flag.Usage()
+	os.Exit(2)
+	return false, nil
+}
+
+func newTile(o options) (cf.DockTile, error) {
+	switch o.tileType {
+	case "spacer":
+		return &cf.SpacerTile{}, nil
+	case "small-spacer":
+		return &cf.SmallSpacerTile{}, nil
+	case "flex-spacer":
+		return &cf.FlexSpacerTile{}, nil
+	case "":
+	default:
+		return nil, fmt.Errorf("unknown tile type %q", o.tileType)
+	}
+
+	tile, err := cf.NewDockTile(o.add)
+	if err != nil {
+		return nil, err
+	}
+	switch t := tile.(type) {
+	case *cf.AppTile:
+		if o.label != "" {
+			t.Label = o.label
+		}
+	case *cf.URLTile:
+		if o.label != "" {
+			t.Label = o.label
+		}
+	case *cf.FolderTile:
+		if o.label != "" {
+			t.Label = o.label
+		}
+		if t.Display, err = cf.ParseFolderDisplay(o.display); err != nil {
+			return nil, err
+		}
+		if t.View, err = cf.ParseFolderView(o.view); err != nil {
+			return nil, err
+		}
+		if t.Arrangement, err = cf.ParseFolderArrangement(o.sort); err != nil {
+			return nil, err
+		}
+	}
+	return tile, nil
+}
+
+// position converts --position, --before and --after
+func position(dock *cf.Dock, section cf.DockSection, o options) (cf.DockPosition, error) {
+	pos := cf.DockPosition{Index: cf.DockEnd, Before: o.before, After: o.after}
+	switch o.position {
+	case "", "end":
+	case "beginning":
+		pos.Index = 0
+	case "middle":
+		tiles := dock.Apps
+		if section == cf.DockOthers {
+			tiles = dock.Others
+		}
+		pos.Index = len(tiles) / 2
+	default:
+		i, err := strconv.Atoi(o.position)
+		if err != nil || i < 1 {
+			return pos, fmt.Errorf("invalid position %q", o.position)
+		}
+		pos.Index = i - 1
+	}
+	return pos, nil
+}
+
+func list(dock *cf.Dock) {
+	for _, section := range []struct {
+		name  string
+		tiles []cf.DockTile
+	}{{"persistent-apps", dock.Apps}, {"persistent-others", dock.Others}} {
+		for _, t := range section.tiles {
+			var label, u string
+			switch t := t.(type) {
+			case *cf.AppTile:
+				label, u = t.Label, t.URL
+			case *cf.FolderTile:
+				label, u = t.Label, t.URL
+			case *cf.URLTile:
+				label, u = t.Label, t.URL
+			default:
+				label = t.TileType()
+			}
+			fmt.Printf("%s\t%s\t%s\n", label, u, section.name)
+		}
+	}
+}
+
+// save writes the tile arrays back if they differ from prefs, and restarts
+// the Dock so that it reads them
+func save(dock *cf.Dock, prefs map[string]interface{}, noRestart bool) error {
+	updated := dock.Encode()
+	patch, err := cf.Diff(prefs, updated)
+	if err != nil {
+		return err
+	}
+	if len(patch) == 0 {
+		fmt.Println("Dock is unchanged")
+		return nil
+	}
+	keys := map[string]interface{}{}
+	for _, key := range []string{"persistent-apps", "persistent-others"} {
+		keys[key] = updated[key]
+	}
+	if err := cf.DockDomain.SetMulti(keys); err != nil {
+		return err
+	}
+	if _, err := cf.DockDomain.Synchronize(); err != nil {
+		return err
+	}
+	if !noRestart && runtime.GOOS == "darwin" {
+		return exec.Command("killall", "Dock").Run()
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package cf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ChangeOp is the kind of a Change.
+type ChangeOp string
+
+const (
+	ChangeAdded   ChangeOp = "added"
+	ChangeRemoved ChangeOp = "removed"
+	ChangeChanged ChangeOp = "changed"
+)
+
+// Change is a single difference between two trees: a node at Path that was
+// added, removed, or changed from Old to New. Old is nil for additions, New
+// for removals.
+type Change struct {
+	Op   ChangeOp
+	Path KeyPath
+	Old  interface{}
+	New  interface{}
+}
+
+// Patch is a list of changes, in the order they have to be applied.
+type Patch []Change
+
+// Diff compares two trees of the kind Goize produces and returns the changes
+// that turn a into b. Dictionaries are compared key by key and arrays element
+// by element, with elements added or removed at the end.
+//
+// Scalars are compared by value, not Go type: integers of different widths are
+// equal if they have the same value, a float32 equals a float64 if they are the
+// same in single precision, dates are compared as instants and NaN equals NaN.
+// An integer never equals a real, as they are different property list types.
+// A nil tree stands for a missing one.
+func Diff(a, b interface{}) (Patch, error) {
+	return diff(a, b, false)
+}
+
+// diffNumbers is Diff, except that integers and reals with the same value are
+// equal. Preferences written by `defaults write -int` and by the applications
+// themselves often disagree on the type of numbers that mean the same.
+func diffNumbers(a, b interface{}) (Patch, error) {
+	return diff(a, b, true)
+}
+
+func diff(a, b interface{}, numeric bool) (Patch, error) {
+	var va, vb Value
+	var err error
+	if a != nil {
+		if va, err = ValueOf(a); err != nil {
+			return nil, err
+		}
+	}
+	if b != nil {
+		if vb, err = ValueOf(b); err != nil {
+			return nil, err
+		}
+	}
+	patch := Patch{}
+	diffValues(&patch, KeyPath{}, va, vb, numeric)
+	return patch, nil
+}
+
+func diffValues(patch *Patch, path KeyPath, a, b Value, numeric bool) {
+	switch {
+	case a == nil && b == nil:
+		return
+	case a == nil:
+		*patch = append(*patch, Change{Op: ChangeAdded, Path: path, New: b.Interface()})
+		return
+	case b == nil:
+		*patch = append(*patch, Change{Op: ChangeRemoved, Path: path, Old: a.Interface()})
+		return
+	}
+
+	switch a := a.(type) {
+	case Dict:
+		if b, ok := b.(Dict); ok {
+			for _, e := range a {
+				v, _ := b.Get(e.Key)
+				diffValues(patch, append(path[:len(path):len(path)], e.Key), e.Value, v, numeric)
+			}
+			for _, e := range b {
+				if _, ok := a.Get(e.Key); !ok {
+					diffValues(patch, append(path[:len(path):len(path)], e.Key), nil, e.Value, numeric)
+				}
+			}
+			return
+		}
+	case Array:
+		if b, ok := b.(Array); ok {
+			for i := 0; i < len(a) && i < len(b); i++ {
+				diffValues(patch, append(path[:len(path):len(path)], strconv.Itoa(i)), a[i], b[i], numeric)
+			}
+			for i := len(a); i < len(b); i++ {
+				diffValues(patch, append(path[:len(path):len(path)], strconv.Itoa(i)), nil, b[i], numeric)
+			}
+			// remove from the end, so that indices stay valid
+			for i := len(a) - 1; i >= len(b); i-- {
+				diffValues(patch, append(path[:len(path):len(path)], strconv.Itoa(i)), a[i], nil, numeric)
+			}
+			return
+		}
+	}
+	if !scalarsEqual(a, b) && !(numeric && numbersEqual(a, b)) {
+		*patch = append(*patch, Change{Op: ChangeChanged, Path: path, Old: a.Interface(), New: b.Interface()})
+	}
+}
+
+// scalarsEqual compares non-container values, see Diff
+func scalarsEqual(a, b Value) bool {
+	switch a := a.(type) {
+	case Integer:
+		b, ok := b.(Integer)
+		if !ok {
+			return false
+		}
+		if ai, ok := a.Int64(); ok {
+			bi, ok := b.Int64()
+			return ok && ai == bi
+		}
+		bu, ok := b.Uint64()
+		return ok && a.Value == bu
+	case Real:
+		b, ok := b.(Real)
+		if !ok {
+			return false
+		}
+		if math.IsNaN(a.Value) || math.IsNaN(b.Value) {
+			return math.IsNaN(a.Value) && math.IsNaN(b.Value)
+		}
+		if a.Width == 4 || b.Width == 4 {
+			return float32(a.Value) == float32(b.Value)
+		}
+		return a.Value == b.Value
+	case Date:
+		b, ok := b.(Date)
+		return ok && a.Equal(b.Time)
+	case Data:
+		b, ok := b.(Data)
+		return ok && bytes.Equal(a, b)
+	case Array, Dict:
+		// a container compared with a scalar
+		return false
+	}
+	return a == b
+}
+
+// numbersEqual reports whether an integer and a real have the same value
+func numbersEqual(a, b Value) bool {
+	if _, ok := a.(Real); ok {
+		a, b = b, a
+	}
+	i, ok := a.(Integer)
+	if !ok {
+		return false
+	}
+	r, ok := b.(Real)
+	if !ok {
+		return false
+	}
+	if r.Value != math.Trunc(r.Value) {
+		return false
+	}
+	if n, ok := i.Int64(); ok {
+		return r.Value >= math.MinInt64 && r.Value < math.MaxInt64 && int64(r.Value) == n
+	}
+	n, ok := i.Uint64()
+	return ok && r.Value >= 0 && r.Value < math.MaxUint64 && uint64(r.Value) == n
+}
+
+// Apply brings the nodes of tree the patch addresses to their new state and
+// returns the updated tree, modifying it in place like KeyPath.Set does.
+//
+// Old values are not checked, so a patch can be applied to a tree other than
+// the one it was computed from: an addition replaces an existing node, a change
+// adds a missing one, and removing a missing node is not an error.
+func (p Patch) Apply(tree interface{}) (interface{}, error) {
+	for _, c := range p {
+		var err error
+		switch c.Op {
+		case ChangeAdded, ChangeChanged:
+			if _, err = c.Path.Get(tree); err == nil {
+				tree, err = c.Path.Set(tree, deepCopy(c.New))
+			} else if _, ok := err.(*KeyPathNotFoundError); ok {
+				tree, err = c.Path.Add(tree, deepCopy(c.New))
+			}
+		case ChangeRemoved:
+			var updated interface{}
+			if updated, err = c.Path.Delete(tree); err == nil {
+				tree = updated
+			} else if _, ok := err.(*KeyPathNotFoundError); ok {
+				err = nil
+			}
+		default:
+			return nil, fmt.Errorf("plist: unknown change %q at %s", c.Op, c.Path)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+// PreferencesSetMulti returns the keys argument of PreferencesSetMulti that
+// applies the patch to a preference domain whose current top-level values are
+// current: the new value of every key the patch touches, or nil for the keys it
+// removes.
+func (p Patch) PreferencesSetMulti(current map[string]interface{}) (map[string]interface{}, error) {
+	keys := map[string]interface{}{}
+	for _, c := range p {
+		if len(c.Path) == 0 {
+			// the whole domain is replaced
+			for key := range current {
+				keys[key] = nil
+			}
+			if m, ok := c.New.(map[string]interface{}); ok {
+				for key := range m {
+					keys[key] = nil
+				}
+			}
+			continue
+		}
+		keys[c.Path[0]] = nil
+	}
+
+	tree, err := p.Apply(deepCopy(current))
+	if err != nil {
+		return nil, err
+	}
+	updated, _ := tree.(map[string]interface{})
+	for key := range keys {
+		if v, ok := updated[key]; ok {
+			keys[key] = v
+		}
+	}
+	return keys, nil
+}
+
+// jsonChange is the JSON form of a Change, with values in the JSON form of
+// property lists
+type jsonChange struct {
+	Op   ChangeOp        `json:"op"`
+	Path string          `json:"path"`
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+}
+
+// MarshalJSON encodes the change as an object with "op", "path", "old" and
+// "new" keys. Values keep their property list types: dates, data and reals
+// that JSON has no form for are written as {"$date": ...}, {"$data": ...} and
+// {"$real": ...} objects.
+func (c Change) MarshalJSON() ([]byte, error) {
+	jc := jsonChange{Op: c.Op, Path: c.Path.String()}
+	var err error
+	if jc.Old, err = marshalJSONPlistValue(c.Old); err != nil {
+		return nil, err
+	}
+	if jc.New, err = marshalJSONPlistValue(c.New); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jc)
+}
+
+func (c *Change) UnmarshalJSON(data []byte) error {
+	var jc jsonChange
+	if err := json.Unmarshal(data, &jc); err != nil {
+		return err
+	}
+	c.Op, c.Path, c.Old, c.New = jc.Op, ParseKeyPath(jc.Path), nil, nil
+	for _, v := range []struct {
+		raw json.RawMessage
+		dst *interface{}
+	}{{jc.Old, &c.Old}, {jc.New, &c.New}} {
+		if len(v.raw) == 0 {
+			continue
+		}
+		val, err := DecodeJSONPlistValue(v.raw)
+		if err != nil {
+			return err
+		}
+		*v.dst = val.Interface()
+	}
+	return nil
+}
+
+func marshalJSONPlistValue(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	val, err := ValueOf(v)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	writeJSONPlistValue(buf, val)
+	return buf.Bytes(), nil
+}
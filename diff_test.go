@@ -0,0 +1,175 @@
+package cf
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	a := map[string]interface{}{
+		"same":    int64(1),
+		"width":   int8(5),
+		"float":   float32(0.1),
+		"nan":     math.NaN(),
+		"date":    date,
+		"data":    []byte{1, 2},
+		"kind":    int64(1),
+		"removed": "x",
+		"nested":  map[string]interface{}{"a": "b", "list": []interface{}{"1", "2", "3"}},
+	}
+	b := map[string]interface{}{
+		"same":   int64(1),
+		"width":  int64(5),
+		"float":  float64(float32(0.1)),
+		"nan":    math.NaN(),
+		"date":   date.Local(),
+		"data":   []byte{1, 3},
+		"kind":   float64(1),
+		"added":  []interface{}{},
+		"nested": map[string]interface{}{"a": "c", "list": []interface{}{"1", "4"}},
+	}
+
+	patch, err := Diff(a, b)
+	require.NoError(t, err)
+	require.Equal(t, Patch{
+		{Op: ChangeChanged, Path: KeyPath{"data"}, Old: []byte{1, 2}, New: []byte{1, 3}},
+		{Op: ChangeChanged, Path: KeyPath{"kind"}, Old: int64(1), New: float64(1)},
+		{Op: ChangeChanged, Path: KeyPath{"nested", "a"}, Old: "b", New: "c"},
+		{Op: ChangeChanged, Path: KeyPath{"nested", "list", "1"}, Old: "2", New: "4"},
+		{Op: ChangeRemoved, Path: KeyPath{"nested", "list", "2"}, Old: "3"},
+		{Op: ChangeRemoved, Path: KeyPath{"removed"}, Old: "x"},
+		{Op: ChangeAdded, Path: KeyPath{"added"}, New: []interface{}(nil)},
+	}, patch)
+
+	patch, err = Diff(a, a)
+	require.NoError(t, err)
+	require.Empty(t, patch)
+
+	patch, err = Diff(nil, "x")
+	require.NoError(t, err)
+	require.Equal(t, Patch{{Op: ChangeAdded, Path: KeyPath{}, New: "x"}}, patch)
+
+	_, err = Diff(map[string]interface{}{"a": make(chan int)}, nil)
+	require.IsType(t, &UnsupportedTypeError{}, err)
+}
+
+func TestDiffNumbers(t *testing.T) {
+	a := map[string]interface{}{"i": int64(48), "r": 1.0, "u": uint64(math.MaxUint64), "f": 0.5, "s": "1"}
+	b := map[string]interface{}{"i": 48.0, "r": int32(1), "u": float64(math.MaxUint64), "f": int64(0), "s": int64(1)}
+	patch, err := diffNumbers(a, b)
+	require.NoError(t, err)
+	require.Equal(t, Patch{
+		{Op: ChangeChanged, Path: KeyPath{"f"}, Old: 0.5, New: int64(0)},
+		{Op: ChangeChanged, Path: KeyPath{"s"}, Old: "1", New: int64(1)},
+		{Op: ChangeChanged, Path: KeyPath{"u"}, Old: uint64(math.MaxUint64), New: float64(math.MaxUint64)},
+	}, patch)
+	patch, err = Diff(a, b)
+	require.NoError(t, err)
+	require.Len(t, patch, 5)
+}
+
+func TestPatchApply(t *testing.T) {
+	a := map[string]interface{}{
+		"keep": "k",
+		"list": []interface{}{int64(1), int64(2), int64(3)},
+		"dict": map[string]interface{}{"x": int64(1)},
+		"gone": true,
+	}
+	b := map[string]interface{}{
+		"keep": "k",
+		"list": []interface{}{int64(1), int64(5)},
+		"dict": map[string]interface{}{"x": int64(2), "y": []interface{}{"new"}},
+		"new":  "n",
+	}
+	patch, err := Diff(a, b)
+	require.NoError(t, err)
+
+	tree, err := patch.Apply(deepCopy(a))
+	require.NoError(t, err)
+	require.Equal(t, b, tree)
+
+	// the patch does not depend on the old values
+	other := map[string]interface{}{"dict": map[string]interface{}{}, "new": "old", "list": []interface{}{"9"}}
+	tree, err = patch.Apply(other)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"list": []interface{}{"9", int64(5)},
+		"dict": map[string]interface{}{"x": int64(2), "y": []interface{}{"new"}},
+		"new":  "n",
+	}, tree)
+
+	// array elements cannot be added past the end
+	_, err = patch.Apply(map[string]interface{}{"dict": map[string]interface{}{}, "list": []interface{}{}})
+	require.IsType(t, &KeyPathNotFoundError{}, err)
+
+	_, err = Patch{{Op: "moved", Path: KeyPath{"a"}}}.Apply(a)
+	require.Error(t, err)
+}
+
+func TestPatchPreferencesSetMulti(t *testing.T) {
+	current := map[string]interface{}{
+		"a": "aval",
+		"b": "bval",
+		"d": map[string]interface{}{"x": int64(1), "y": int64(2)},
+		"e": "untouched",
+	}
+	patch, err := Diff(current, map[string]interface{}{
+		"a": "aval2",
+		"c": "cval",
+		"d": map[string]interface{}{"x": int64(1), "y": int64(3)},
+		"e": "untouched",
+	})
+	require.NoError(t, err)
+
+	keys, err := patch.PreferencesSetMulti(current)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"a": "aval2",
+		"b": nil,
+		"c": "cval",
+		"d": map[string]interface{}{"x": int64(1), "y": int64(3)},
+	}, keys)
+	// current is left alone
+	require.Equal(t, "bval", current["b"])
+}
+
+func TestPatchJSON(t *testing.T) {
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	patch := Patch{
+		{Op: ChangeChanged, Path: KeyPath{"a:b", "0"}, Old: int64(1), New: float64(1)},
+		{Op: ChangeAdded, Path: KeyPath{"d"}, New: map[string]interface{}{
+			"date": date, "data": []byte{1, 2}, "inf": math.Inf(1), "$x": "y",
+		}},
+		{Op: ChangeRemoved, Path: KeyPath{"big"}, Old: uint64(math.MaxUint64)},
+	}
+	data, err := json.Marshal(patch)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"op": "changed", "path": ":a\\:b:0", "old": 1, "new": 1.0},
+		{"op": "added", "path": ":d", "new": {
+			"$x": "y",
+			"data": {"$data": "AQI="},
+			"date": {"$date": "2020-01-02T03:04:05Z"},
+			"inf": {"$real": "+infinity"}
+		}},
+		{"op": "removed", "path": ":big", "old": 18446744073709551615}
+	]`, string(data))
+
+	var decoded Patch
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, patch[0], decoded[0])
+	require.Equal(t, patch[2], decoded[2])
+	d := decoded[1].New.(map[string]interface{})
+	require.True(t, date.Equal(d["date"].(time.Time)))
+	require.Equal(t, []byte{1, 2}, d["data"])
+	require.Equal(t, math.Inf(1), d["inf"])
+	require.Equal(t, "y", d["$x"])
+
+	require.Error(t, json.Unmarshal([]byte(`[{"op": "added", "path": ":a", "new": {"$date": 1}}]`), &decoded))
+	require.Error(t, json.Unmarshal([]byte(`[{"op": "added", "path": ":a", "new": null}]`), &decoded))
+}
@@ -0,0 +1,472 @@
+package cf
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// The Dock keeps its tiles in the com.apple.dock domain, in the
+// persistent-apps (left of the divider) and persistent-others (right of the
+// divider) arrays. Every tile is a dictionary
+//
+//	{
+//	  "GUID": 1234567890,
+//	  "tile-type": "file-tile",
+//	  "tile-data": {
+//	    "file-label": "Safari",
+//	    "bundle-identifier": "com.apple.Safari",
+//	    "file-data": {"_CFURLString": "file:///Applications/Safari.app/", "_CFURLStringType": 15},
+//	    "file-type": 41,
+//	    ...
+//	  }
+//	}
+//
+// with folders as "directory-tile", web links as "url-tile" (with "label" and a
+// "url" dictionary in tile-data) and the three kinds of spacers as
+// "spacer-tile", "small-spacer-tile" and "flex-spacer-tile" with empty
+// tile-data.
+
+const (
+	dockTileTypeApp         = "file-tile"
+	dockTileTypeFolder      = "directory-tile"
+	dockTileTypeURL         = "url-tile"
+	dockTileTypeSpacer      = "spacer-tile"
+	dockTileTypeSmallSpacer = "small-spacer-tile"
+	dockTileTypeFlexSpacer  = "flex-spacer-tile"
+
+	// _CFURLStringType of absolute URL strings
+	dockURLStringType = 15
+	// file-type of applications and of folders
+	dockFileTypeApp    = 41
+	dockFileTypeFolder = 2
+)
+
+// DockTile is a tile of the Dock: *AppTile, *FolderTile, *URLTile,
+// *SpacerTile, *SmallSpacerTile, *FlexSpacerTile, or *OtherTile for tile types
+// the model does not know.
+type DockTile interface {
+	// TileType is the tile-type of the tile
+	TileType() string
+	// Info returns what all tiles have
+	Info() *DockTileInfo
+}
+
+// DockTileInfo holds what all tiles have.
+type DockTileInfo struct {
+	// GUID identifies the tile. The Dock assigns one to tiles without it.
+	GUID int64
+	// Raw is the dictionary the tile was decoded from, or nil. EncodeDockTile
+	// starts from it, so that keys the model does not know are kept.
+	Raw map[string]interface{}
+}
+
+func (i *DockTileInfo) Info() *DockTileInfo {
+	return i
+}
+
+// AppTile is a file tile: an application, or any other file.
+type AppTile struct {
+	DockTileInfo
+	Label string
+	// BundleID is the bundle identifier of the application
+	BundleID string
+	// URL is the file URL of the application, e.g.
+	// "file:///Applications/Safari.app/"
+	URL string
+}
+
+// FolderTile is a folder, shown as a stack or as a folder icon.
+type FolderTile struct {
+	DockTileInfo
+	Label string
+	// URL is the file URL of the folder, e.g. "file:///Users/me/Downloads/"
+	URL         string
+	Display     FolderDisplay
+	View        FolderView
+	Arrangement FolderArrangement
+}
+
+// URLTile is a link to a web page.
+type URLTile struct {
+	DockTileInfo
+	Label string
+	URL   string
+}
+
+// SpacerTile is an empty space the size of a tile.
+type SpacerTile struct {
+	DockTileInfo
+}
+
+// SmallSpacerTile is an empty space half the size of a tile.
+type SmallSpacerTile struct {
+	DockTileInfo
+}
+
+// FlexSpacerTile is an empty space that grows to push the tiles after it to
+// the end of the Dock.
+type FlexSpacerTile struct {
+	DockTileInfo
+}
+
+// OtherTile is a tile of a type the model does not know. It is kept as it is.
+type OtherTile struct {
+	DockTileInfo
+	Type string
+}
+
+func (*AppTile) TileType() string         { return dockTileTypeApp }
+func (*FolderTile) TileType() string      { return dockTileTypeFolder }
+func (*URLTile) TileType() string         { return dockTileTypeURL }
+func (*SpacerTile) TileType() string      { return dockTileTypeSpacer }
+func (*SmallSpacerTile) TileType() string { return dockTileTypeSmallSpacer }
+func (*FlexSpacerTile) TileType() string  { return dockTileTypeFlexSpacer }
+func (t *OtherTile) TileType() string     { return t.Type }
+
+// FolderDisplay is how a folder tile looks in the Dock: the "displayas" key.
+type FolderDisplay int
+
+const (
+	// FolderDisplayStack shows the items of the folder stacked
+	FolderDisplayStack FolderDisplay = 0
+	// FolderDisplayFolder shows the icon of the folder
+	FolderDisplayFolder FolderDisplay = 1
+)
+
+func (d FolderDisplay) String() string {
+	switch d {
+	case FolderDisplayStack:
+		return "stack"
+	case FolderDisplayFolder:
+		return "folder"
+	}
+	return fmt.Sprintf("FolderDisplay(%d)", int(d))
+}
+
+// FolderView is how an opened folder tile shows its items: the "showas" key.
+type FolderView int
+
+const (
+	FolderViewAuto FolderView = 0
+	FolderViewFan  FolderView = 1
+	FolderViewGrid FolderView = 2
+	FolderViewList FolderView = 3
+)
+
+func (v FolderView) String() string {
+	switch v {
+	case FolderViewAuto:
+		return "auto"
+	case FolderViewFan:
+		return "fan"
+	case FolderViewGrid:
+		return "grid"
+	case FolderViewList:
+		return "list"
+	}
+	return fmt.Sprintf("FolderView(%d)", int(v))
+}
+
+// FolderArrangement is the order of the items of a folder tile: the
+// "arrangement" key.
+type FolderArrangement int
+
+const (
+	FolderArrangementName         FolderArrangement = 1
+	FolderArrangementDateAdded    FolderArrangement = 2
+	FolderArrangementDateModified FolderArrangement = 3
+	FolderArrangementDateCreated  FolderArrangement = 4
+	FolderArrangementKind         FolderArrangement = 5
+)
+
+func (a FolderArrangement) String() string {
+	switch a {
+	case FolderArrangementName:
+		return "name"
+	case FolderArrangementDateAdded:
+		return "dateadded"
+	case FolderArrangementDateModified:
+		return "datemodified"
+	case FolderArrangementDateCreated:
+		return "datecreated"
+	case FolderArrangementKind:
+		return "kind"
+	}
+	return fmt.Sprintf("FolderArrangement(%d)", int(a))
+}
+
+// ParseFolderDisplay parses the names String returns, as dockutil's --display
+// flag takes them.
+func ParseFolderDisplay(s string) (FolderDisplay, error) {
+	for _, d := range []FolderDisplay{FolderDisplayStack, FolderDisplayFolder} {
+		if s == d.String() {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("plist: unknown folder display %q", s)
+}
+
+// ParseFolderView parses the names String returns, as dockutil's --view flag
+// takes them.
+func ParseFolderView(s string) (FolderView, error) {
+	for v := FolderViewAuto; v <= FolderViewList; v++ {
+		if s == v.String() {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("plist: unknown folder view %q", s)
+}
+
+// ParseFolderArrangement parses the names String returns, as dockutil's --sort
+// flag takes them.
+func ParseFolderArrangement(s string) (FolderArrangement, error) {
+	for a := FolderArrangementName; a <= FolderArrangementKind; a++ {
+		if s == a.String() {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("plist: unknown folder arrangement %q", s)
+}
+
+// InvalidDockTileError is returned when a Dock tile is not a dictionary, or
+// a key of a tile has the wrong type.
+type InvalidDockTileError struct {
+	Reason string
+}
+
+func (e *InvalidDockTileError) Error() string {
+	return "plist: invalid Dock tile: " + e.Reason
+}
+
+// DecodeDockTile decodes a tile dictionary, as found in the persistent-apps
+// and persistent-others arrays.
+func DecodeDockTile(v interface{}) (DockTile, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, &InvalidDockTileError{fmt.Sprintf("%T is not a dictionary", v)}
+	}
+	r := &dockTileReader{}
+	info := DockTileInfo{GUID: r.int("GUID", m), Raw: deepCopy(m).(map[string]interface{})}
+	typ := r.string("tile-type", m)
+	data := r.dict("tile-data", m)
+	urlKey := "file-data"
+	if typ == dockTileTypeURL {
+		urlKey = "url"
+	}
+	url := r.string("_CFURLString", r.dict(urlKey, data))
+
+	var tile DockTile
+	switch typ {
+	case dockTileTypeApp:
+		tile = &AppTile{info, r.string("file-label", data), r.string("bundle-identifier", data), url}
+	case dockTileTypeFolder:
+		tile = &FolderTile{info, r.string("file-label", data), url,
+			FolderDisplay(r.int("displayas", data)), FolderView(r.int("showas", data)),
+			FolderArrangement(r.int("arrangement", data))}
+	case dockTileTypeURL:
+		tile = &URLTile{info, r.string("label", data), url}
+	case dockTileTypeSpacer:
+		tile = &SpacerTile{info}
+	case dockTileTypeSmallSpacer:
+		tile = &SmallSpacerTile{info}
+	case dockTileTypeFlexSpacer:
+		tile = &FlexSpacerTile{info}
+	default:
+		tile = &OtherTile{info, typ}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return tile, nil
+}
+
+// dockTileReader reads the keys of a tile, remembering the first error
+type dockTileReader struct {
+	err error
+}
+
+func (r *dockTileReader) fail(key string, v interface{}, typ string) {
+	if r.err == nil {
+		r.err = &InvalidDockTileError{fmt.Sprintf("%s is %T, not %s", key, v, typ)}
+	}
+}
+
+func (r *dockTileReader) string(key string, m map[string]interface{}) string {
+	v, ok := m[key]
+	if !ok {
+		return ""
+	}
+	s, ok := v.(string)
+	if !ok {
+		r.fail(key, v, "a string")
+	}
+	return s
+}
+
+func (r *dockTileReader) int(key string, m map[string]interface{}) int64 {
+	v, ok := m[key]
+	if !ok {
+		return 0
+	}
+	if val, err := ValueOf(v); err == nil {
+		if i, ok := val.(Integer); ok {
+			if i, ok := i.Int64(); ok {
+				return i
+			}
+		}
+	}
+	r.fail(key, v, "an integer")
+	return 0
+}
+
+func (r *dockTileReader) dict(key string, m map[string]interface{}) map[string]interface{} {
+	v, ok := m[key]
+	if !ok {
+		return nil
+	}
+	d, ok := v.(map[string]interface{})
+	if !ok {
+		r.fail(key, v, "a dictionary")
+	}
+	return d
+}
+
+// EncodeDockTile encodes a tile into the dictionary the Dock expects. Keys of
+// Raw that the model does not know are kept; the known ones are replaced by
+// the fields of the tile, and left out when a field is empty and the key was
+// not in Raw.
+func EncodeDockTile(t DockTile) map[string]interface{} {
+	info := t.Info()
+	m := map[string]interface{}{}
+	if info.Raw != nil {
+		m = deepCopy(info.Raw).(map[string]interface{})
+	}
+	data, _ := m["tile-data"].(map[string]interface{})
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	m["tile-type"] = t.TileType()
+	m["tile-data"] = data
+	setDockInt(m, "GUID", info.GUID)
+
+	switch t := t.(type) {
+	case *AppTile:
+		setDockString(data, "file-label", t.Label)
+		setDockString(data, "bundle-identifier", t.BundleID)
+		setDockURL(data, "file-data", t.URL)
+		if info.Raw == nil {
+			data["file-type"] = int64(dockFileTypeApp)
+		}
+	case *FolderTile:
+		setDockString(data, "file-label", t.Label)
+		setDockURL(data, "file-data", t.URL)
+		if info.Raw == nil {
+			data["file-type"] = int64(dockFileTypeFolder)
+		}
+		setDockInt(data, "displayas", int64(t.Display))
+		setDockInt(data, "showas", int64(t.View))
+		setDockInt(data, "arrangement", int64(t.Arrangement))
+	case *URLTile:
+		setDockString(data, "label", t.Label)
+		setDockURL(data, "url", t.URL)
+	}
+	return m
+}
+
+func setDockString(m map[string]interface{}, key, s string) {
+	if _, ok := m[key]; ok || s != "" {
+		m[key] = s
+	}
+}
+
+func setDockInt(m map[string]interface{}, key string, i int64) {
+	if _, ok := m[key]; ok || i != 0 {
+		m[key] = i
+	}
+}
+
+// setDockURL sets the _CFURLString of the URL dictionary at key
+func setDockURL(m map[string]interface{}, key, url string) {
+	u, _ := m[key].(map[string]interface{})
+	if u == nil {
+		if url == "" {
+			return
+		}
+		u = map[string]interface{}{"_CFURLStringType": int64(dockURLStringType)}
+		m[key] = u
+	}
+	u["_CFURLString"] = url
+}
+
+// Dock is the layout of the Dock: the tiles before and after the divider.
+type Dock struct {
+	// Apps are the tiles of persistent-apps
+	Apps []DockTile
+	// Others are the tiles of persistent-others
+	Others []DockTile
+	// Raw holds the preferences the Dock was decoded from, or nil. Encode
+	// keeps the keys other than the tile arrays.
+	Raw map[string]interface{}
+}
+
+// DecodeDock decodes the tiles of the Dock preferences, as returned by
+// CoreDockCopyPreferences or read from the com.apple.dock domain.
+func DecodeDock(prefs map[string]interface{}) (*Dock, error) {
+	d := &Dock{Raw: deepCopy(prefs).(map[string]interface{})}
+	var err error
+	if d.Apps, err = decodeDockTiles(prefs, "persistent-apps"); err != nil {
+		return nil, err
+	}
+	if d.Others, err = decodeDockTiles(prefs, "persistent-others"); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func decodeDockTiles(prefs map[string]interface{}, key string) ([]DockTile, error) {
+	v, ok := prefs[key]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("plist: %s is %T, not an array", key, v)
+	}
+	tiles := make([]DockTile, len(a))
+	for i, t := range a {
+		var err error
+		if tiles[i], err = DecodeDockTile(t); err != nil {
+			return nil, errors.Wrapf(err, "%s %d", key, i)
+		}
+	}
+	return tiles, nil
+}
+
+// Encode returns the Dock preferences: Raw with the tile arrays replaced.
+func (d *Dock) Encode() map[string]interface{} {
+	prefs := map[string]interface{}{}
+	if d.Raw != nil {
+		prefs = deepCopy(d.Raw).(map[string]interface{})
+	}
+	encodeDockTiles(prefs, "persistent-apps", d.Apps)
+	encodeDockTiles(prefs, "persistent-others", d.Others)
+	return prefs
+}
+
+// encodeDockTiles sets the tile array at key, unless it is empty and was not
+// there
+func encodeDockTiles(prefs map[string]interface{}, key string, tiles []DockTile) {
+	if _, ok := prefs[key]; !ok && len(tiles) == 0 {
+		return
+	}
+	prefs[key] = encodeDockTileArray(tiles)
+}
+
+func encodeDockTileArray(tiles []DockTile) []interface{} {
+	a := make([]interface{}, len(tiles))
+	for i, t := range tiles {
+		a[i] = EncodeDockTile(t)
+	}
+	return a
+}
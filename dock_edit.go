@@ -0,0 +1,326 @@
+package cf
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DockSection is one of the tile arrays of the Dock, named as in dockutil's
+// --section flag.
+type DockSection string
+
+const (
+	// DockApps is persistent-apps, before the divider
+	DockApps DockSection = "apps"
+	// DockOthers is persistent-others, after the divider
+	DockOthers DockSection = "others"
+)
+
+// DockEnd is the Index of a DockPosition at the end of a section.
+const DockEnd = -1
+
+// DockPosition is where Add and Move put a tile: before or after the tile
+// matching a name, or at an index of the section.
+type DockPosition struct {
+	// Index is used when Before and After are empty. DockEnd appends, and
+	// indices past the end are the end.
+	Index  int
+	Before string
+	After  string
+}
+
+// DockTileNotFoundError is returned by Dock operations when no tile matches a
+// name.
+type DockTileNotFoundError struct {
+	Name string
+}
+
+func (e *DockTileNotFoundError) Error() string {
+	return fmt.Sprintf("plist: no Dock tile matches %q", e.Name)
+}
+
+// DockTileMatches reports whether name names the tile: its label, bundle
+// identifier or URL, or the path of a file URL.
+func DockTileMatches(t DockTile, name string) bool {
+	var label, bundleID, u string
+	switch t := t.(type) {
+	case *AppTile:
+		label, bundleID, u = t.Label, t.BundleID, t.URL
+	case *FolderTile:
+		label, u = t.Label, t.URL
+	case *URLTile:
+		label, u = t.Label, t.URL
+	default:
+		return false
+	}
+	switch name {
+	case "":
+		return false
+	case label, bundleID, u:
+		return true
+	}
+	if p, ok := dockFilePath(u); ok && strings.HasPrefix(name, "/") {
+		return strings.TrimSuffix(p, "/") == strings.TrimSuffix(name, "/")
+	}
+	return false
+}
+
+// dockFilePath returns the path of a file URL
+func dockFilePath(u string) (string, bool) {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Scheme != "file" {
+		return "", false
+	}
+	return parsed.Path, true
+}
+
+// dockTileKey identifies what a tile opens, for finding duplicates. Spacers and
+// unknown tiles have no key.
+func dockTileKey(t DockTile) string {
+	switch t := t.(type) {
+	case *AppTile:
+		if t.BundleID != "" {
+			return "bundle:" + t.BundleID
+		}
+		return "url:" + strings.TrimSuffix(t.URL, "/")
+	case *FolderTile:
+		return "url:" + strings.TrimSuffix(t.URL, "/")
+	case *URLTile:
+		return "url:" + t.URL
+	}
+	return ""
+}
+
+func (d *Dock) section(s DockSection) (*[]DockTile, error) {
+	switch s {
+	case DockApps:
+		return &d.Apps, nil
+	case DockOthers:
+		return &d.Others, nil
+	}
+	return nil, fmt.Errorf("plist: unknown Dock section %q", s)
+}
+
+// Find returns the section and index of the first tile name matches.
+func (d *Dock) Find(name string) (DockSection, int, bool) {
+	for _, s := range []DockSection{DockApps, DockOthers} {
+		tiles, _ := d.section(s)
+		for i, t := range *tiles {
+			if DockTileMatches(t, name) {
+				return s, i, true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// index resolves a position in tiles, which do not include the tile being
+// placed
+func (p DockPosition) index(tiles []DockTile) (int, error) {
+	for _, rel := range []struct {
+		name   string
+		offset int
+	}{{p.Before, 0}, {p.After, 1}} {
+		if rel.name == "" {
+			continue
+		}
+		for i, t := range tiles {
+			if DockTileMatches(t, rel.name) {
+				return i + rel.offset, nil
+			}
+		}
+		return 0, &DockTileNotFoundError{rel.name}
+	}
+	if p.Index < 0 || p.Index > len(tiles) {
+		return len(tiles), nil
+	}
+	return p.Index, nil
+}
+
+// Add inserts tile into the section at pos, and reports whether the Dock
+// changed. As with dockutil, a tile that is in the Dock already is not added
+// again. Spacers have no identity and are always added.
+func (d *Dock) Add(s DockSection, tile DockTile, pos DockPosition) (bool, error) {
+	tiles, err := d.section(s)
+	if err != nil {
+		return false, err
+	}
+	if key := dockTileKey(tile); key != "" {
+		for _, other := range append(d.Apps[:len(d.Apps):len(d.Apps)], d.Others...) {
+			if dockTileKey(other) == key {
+				return false, nil
+			}
+		}
+	}
+	i, err := pos.index(*tiles)
+	if err != nil {
+		return false, err
+	}
+	*tiles = append(*tiles, nil)
+	copy((*tiles)[i+1:], (*tiles)[i:])
+	(*tiles)[i] = tile
+	return true, nil
+}
+
+// Replace puts tile in place of the first tile name matches, keeping its GUID
+// if tile has none, and reports whether the Dock changed.
+func (d *Dock) Replace(name string, tile DockTile) (bool, error) {
+	s, i, ok := d.Find(name)
+	if !ok {
+		return false, &DockTileNotFoundError{name}
+	}
+	tiles, _ := d.section(s)
+	old := (*tiles)[i]
+	if tile.Info().GUID == 0 {
+		tile.Info().GUID = old.Info().GUID
+	}
+	if patch, err := Diff(EncodeDockTile(old), EncodeDockTile(tile)); err == nil && len(patch) == 0 {
+		return false, nil
+	}
+	(*tiles)[i] = tile
+	return true, nil
+}
+
+// Remove removes every tile name matches and reports how many there were.
+func (d *Dock) Remove(name string) int {
+	removed := 0
+	for _, s := range []DockSection{DockApps, DockOthers} {
+		n, _ := d.RemoveFrom(s, name)
+		removed += n
+	}
+	return removed
+}
+
+// RemoveFrom removes every tile of the section name matches and reports how
+// many there were.
+func (d *Dock) RemoveFrom(s DockSection, name string) (int, error) {
+	tiles, err := d.section(s)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	kept := (*tiles)[:0]
+	for _, t := range *tiles {
+		if DockTileMatches(t, name) {
+			removed++
+		} else {
+			kept = append(kept, t)
+		}
+	}
+	*tiles = kept
+	return removed, nil
+}
+
+// RemoveAll removes all tiles of the section, spacers included, and reports
+// whether there were any.
+func (d *Dock) RemoveAll(s DockSection) (bool, error) {
+	tiles, err := d.section(s)
+	if err != nil {
+		return false, err
+	}
+	removed := len(*tiles) > 0
+	*tiles = nil
+	return removed, nil
+}
+
+// Move moves the first tile name matches to pos in its section, and reports
+// whether the Dock changed.
+func (d *Dock) Move(name string, pos DockPosition) (bool, error) {
+	s, i, ok := d.Find(name)
+	if !ok {
+		return false, &DockTileNotFoundError{name}
+	}
+	tiles, _ := d.section(s)
+	tile := (*tiles)[i]
+	rest := append(append([]DockTile{}, (*tiles)[:i]...), (*tiles)[i+1:]...)
+	j, err := pos.index(rest)
+	if err != nil {
+		return false, err
+	}
+	if i == j {
+		return false, nil
+	}
+	rest = append(rest, nil)
+	copy(rest[j+1:], rest[j:])
+	rest[j] = tile
+	*tiles = rest
+	return true, nil
+}
+
+// Dedupe removes the tiles that open what an earlier tile opens, and reports
+// how many there were. Spacers are kept.
+func (d *Dock) Dedupe() int {
+	seen := map[string]bool{}
+	removed := 0
+	for _, s := range []DockSection{DockApps, DockOthers} {
+		tiles, _ := d.section(s)
+		kept := (*tiles)[:0]
+		for _, t := range *tiles {
+			key := dockTileKey(t)
+			if key != "" && seen[key] {
+				removed++
+				continue
+			}
+			seen[key] = true
+			kept = append(kept, t)
+		}
+		*tiles = kept
+	}
+	return removed
+}
+
+// NewDockTile makes a tile for a path or URL the way dockutil's --add does:
+// an AppTile for applications and other files, with the bundle identifier of
+// applications read from their Info.plist, a FolderTile for directories and a
+// URLTile for URLs other than file URLs. Tiles are labeled with the file name
+// without extension, or with the URL.
+//
+// Paths that do not exist, as when seeding a Dock offline on another system,
+// are applications if they end in ".app", folders if they end in a slash and
+// other files otherwise.
+func NewDockTile(pathOrURL string) (DockTile, error) {
+	if strings.Contains(pathOrURL, "://") && !strings.HasPrefix(pathOrURL, "file://") {
+		return &URLTile{Label: pathOrURL, URL: pathOrURL}, nil
+	}
+	path := pathOrURL
+	if p, ok := dockFilePath(pathOrURL); ok {
+		path = p
+	}
+	trailingSlash := strings.HasSuffix(path, "/") || strings.HasSuffix(path, string(filepath.Separator))
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	var isDir bool
+	switch fi, err := os.Stat(path); {
+	case err == nil:
+		isDir = fi.IsDir()
+	case os.IsNotExist(err):
+		isDir = trailingSlash || filepath.Ext(path) == ".app"
+	default:
+		return nil, err
+	}
+
+	label := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	u := (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+	if !isDir {
+		return &AppTile{Label: label, URL: u}, nil
+	}
+	u += "/"
+	if filepath.Ext(path) != ".app" {
+		return &FolderTile{Label: filepath.Base(path), URL: u, Arrangement: FolderArrangementName}, nil
+	}
+	tile := &AppTile{Label: label, URL: u}
+	if f, err := os.Open(filepath.Join(path, "Contents", "Info.plist")); err == nil {
+		defer f.Close()
+		if info, _, err := Decode(f); err == nil {
+			if m, ok := info.(map[string]interface{}); ok {
+				tile.BundleID, _ = m["CFBundleIdentifier"].(string)
+			}
+		}
+	}
+	return tile, nil
+}
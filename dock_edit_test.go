@@ -0,0 +1,197 @@
+package cf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func dockLabels(tiles []DockTile) []string {
+	labels := make([]string, len(tiles))
+	for i, t := range tiles {
+		switch t := t.(type) {
+		case *AppTile:
+			labels[i] = t.Label
+		case *FolderTile:
+			labels[i] = t.Label
+		case *URLTile:
+			labels[i] = t.Label
+		default:
+			labels[i] = t.TileType()
+		}
+	}
+	return labels
+}
+
+func testDock() *Dock {
+	return &Dock{
+		Apps: []DockTile{
+			&AppTile{Label: "Safari", BundleID: "com.apple.Safari", URL: "file:///Applications/Safari.app/"},
+			&AppTile{Label: "Mail", BundleID: "com.apple.mail", URL: "file:///System/Applications/Mail.app/"},
+			&AppTile{Label: "Notes", BundleID: "com.apple.Notes", URL: "file:///System/Applications/Notes.app/"},
+		},
+		Others: []DockTile{
+			&FolderTile{Label: "Downloads", URL: "file:///Users/me/Downloads/"},
+		},
+	}
+}
+
+func TestDockTileMatches(t *testing.T) {
+	safari := &AppTile{Label: "Safari", BundleID: "com.apple.Safari", URL: "file:///Applications/Safari.app/"}
+	for _, name := range []string{"Safari", "com.apple.Safari", "file:///Applications/Safari.app/", "/Applications/Safari.app"} {
+		require.True(t, DockTileMatches(safari, name), name)
+	}
+	require.False(t, DockTileMatches(safari, "safari"))
+	require.False(t, DockTileMatches(safari, ""))
+	require.False(t, DockTileMatches(&SpacerTile{}, "spacer-tile"))
+}
+
+func TestDockAdd(t *testing.T) {
+	d := testDock()
+	term := &AppTile{Label: "Terminal", BundleID: "com.apple.Terminal"}
+
+	changed, err := d.Add(DockApps, term, DockPosition{After: "Safari"})
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, []string{"Safari", "Terminal", "Mail", "Notes"}, dockLabels(d.Apps))
+
+	// already in the Dock
+	changed, err = d.Add(DockApps, &AppTile{Label: "Terminal 2", BundleID: "com.apple.Terminal"}, DockPosition{Index: DockEnd})
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	changed, err = d.Add(DockApps, &AppTile{Label: "Music", BundleID: "com.apple.Music"}, DockPosition{Before: "com.apple.Safari"})
+	require.NoError(t, err)
+	require.True(t, changed)
+	changed, err = d.Add(DockApps, &AppTile{Label: "Maps", BundleID: "com.apple.Maps"}, DockPosition{Index: 100})
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, []string{"Music", "Safari", "Terminal", "Mail", "Notes", "Maps"}, dockLabels(d.Apps))
+
+	// spacers are always added, even next to each other
+	for _, pos := range []DockPosition{{Index: 2}, {After: "Safari"}} {
+		changed, err = d.Add(DockApps, &SpacerTile{}, pos)
+		require.NoError(t, err)
+		require.True(t, changed)
+	}
+	changed, err = d.Add(DockApps, &SmallSpacerTile{}, DockPosition{Index: 2})
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, []string{"Music", "Safari", "small-spacer-tile", "spacer-tile", "spacer-tile", "Terminal", "Mail", "Notes", "Maps"}, dockLabels(d.Apps))
+
+	_, err = d.Add(DockApps, &AppTile{Label: "X", BundleID: "x"}, DockPosition{After: "Missing"})
+	require.IsType(t, &DockTileNotFoundError{}, err)
+	_, err = d.Add("trash", &AppTile{Label: "X", BundleID: "x"}, DockPosition{})
+	require.Error(t, err)
+}
+
+func TestDockReplaceRemoveMove(t *testing.T) {
+	d := testDock()
+	d.Apps[1].Info().GUID = 42
+
+	changed, err := d.Replace("Mail", &AppTile{Label: "Outlook", BundleID: "com.microsoft.Outlook"})
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, int64(42), d.Apps[1].Info().GUID)
+	changed, err = d.Replace("Outlook", &AppTile{Label: "Outlook", BundleID: "com.microsoft.Outlook"})
+	require.NoError(t, err)
+	require.False(t, changed)
+	_, err = d.Replace("Mail", &AppTile{})
+	require.IsType(t, &DockTileNotFoundError{}, err)
+
+	changed, err = d.Move("Safari", DockPosition{Index: DockEnd})
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, []string{"Outlook", "Notes", "Safari"}, dockLabels(d.Apps))
+	changed, err = d.Move("Safari", DockPosition{After: "Notes"})
+	require.NoError(t, err)
+	require.False(t, changed)
+	changed, err = d.Move("Safari", DockPosition{Before: "Notes"})
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, []string{"Outlook", "Safari", "Notes"}, dockLabels(d.Apps))
+	changed, err = d.Move("Downloads", DockPosition{Index: 0})
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	removed, err := d.RemoveFrom(DockOthers, "Safari")
+	require.NoError(t, err)
+	require.Equal(t, 0, removed)
+	removed, err = d.RemoveFrom(DockApps, "Safari")
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+	_, err = d.RemoveFrom("trash", "Safari")
+	require.Error(t, err)
+	require.Equal(t, 1, d.Remove("Notes"))
+	require.Equal(t, 0, d.Remove("Notes"))
+	require.Equal(t, []string{"Outlook"}, dockLabels(d.Apps))
+	all, err := d.RemoveAll(DockOthers)
+	require.NoError(t, err)
+	require.True(t, all)
+	require.Empty(t, d.Others)
+}
+
+func TestDockDedupe(t *testing.T) {
+	d := testDock()
+	d.Apps = append(d.Apps, &SpacerTile{}, &SpacerTile{},
+		&AppTile{Label: "Safari copy", BundleID: "com.apple.Safari"})
+	d.Others = append(d.Others, &FolderTile{Label: "Downloads", URL: "file:///Users/me/Downloads"})
+	require.Equal(t, 2, d.Dedupe())
+	require.Equal(t, []string{"Safari", "Mail", "Notes", "spacer-tile", "spacer-tile"}, dockLabels(d.Apps))
+	require.Len(t, d.Others, 1)
+	require.Equal(t, 0, d.Dedupe())
+}
+
+func TestNewDockTile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cf-dock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	app := filepath.Join(dir, "Example App.app")
+	require.NoError(t, os.MkdirAll(filepath.Join(app, "Contents"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(app, "Contents", "Info.plist"),
+		[]byte(xmlPlistHeader+"<dict><key>CFBundleIdentifier</key><string>com.example.app</string></dict>"+xmlPlistFooter), 0644))
+	tile, err := NewDockTile(app)
+	require.NoError(t, err)
+	require.Equal(t, &AppTile{Label: "Example App", BundleID: "com.example.app",
+		URL: "file://" + filepath.ToSlash(dir) + "/Example%20App.app/"}, tile)
+
+	tile, err = NewDockTile(dir)
+	require.NoError(t, err)
+	require.IsType(t, &FolderTile{}, tile)
+	require.Equal(t, "file://"+filepath.ToSlash(dir)+"/", tile.(*FolderTile).URL)
+
+	tile, err = NewDockTile("https://go.dev/")
+	require.NoError(t, err)
+	require.Equal(t, &URLTile{Label: "https://go.dev/", URL: "https://go.dev/"}, tile)
+
+	// paths that do not exist are inferred from their names
+	missing := filepath.Join(dir, "missing")
+	tile, err = NewDockTile(missing + ".app")
+	require.NoError(t, err)
+	require.Equal(t, &AppTile{Label: "missing", URL: "file://" + filepath.ToSlash(missing) + ".app/"}, tile)
+	tile, err = NewDockTile(missing + "/")
+	require.NoError(t, err)
+	require.IsType(t, &FolderTile{}, tile)
+	require.Equal(t, "file://"+filepath.ToSlash(missing)+"/", tile.(*FolderTile).URL)
+	tile, err = NewDockTile(missing + ".pdf")
+	require.NoError(t, err)
+	require.Equal(t, &AppTile{Label: "missing", URL: "file://" + filepath.ToSlash(missing) + ".pdf"}, tile)
+}
+
+func TestParseFolderOptions(t *testing.T) {
+	d, err := ParseFolderDisplay("folder")
+	require.NoError(t, err)
+	require.Equal(t, FolderDisplayFolder, d)
+	v, err := ParseFolderView("list")
+	require.NoError(t, err)
+	require.Equal(t, FolderViewList, v)
+	a, err := ParseFolderArrangement("kind")
+	require.NoError(t, err)
+	require.Equal(t, FolderArrangementKind, a)
+	_, err = ParseFolderView("cover flow")
+	require.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package cf
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// DockFile is a com.apple.dock.plist file edited offline, without the Dock:
+// in user template directories, mounted home folders or on other systems.
+type DockFile struct {
+	Path string
+	// Format is the format the file is written back in: the one it was read
+	// in, or binary for new files
+	Format Format
+	Dock   *Dock
+}
+
+// DockFilePath is the path of the Dock preferences in a home directory.
+const DockFilePath = "Library/Preferences/com.apple.dock.plist"
+
+// OpenDockFile reads the Dock layout from a plist file in any format. A missing
+// file is an empty Dock, written in the binary format.
+func OpenDockFile(path string) (*DockFile, error) {
+	f := &DockFile{Path: path, Format: BinaryFormat}
+	prefs := map[string]interface{}{}
+	r, err := os.Open(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		defer r.Close()
+		var v interface{}
+		if v, f.Format, err = Decode(r); err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", path)
+		}
+		var ok bool
+		if prefs, ok = v.(map[string]interface{}); !ok {
+			return nil, errors.Errorf("%s does not contain a dictionary", path)
+		}
+	}
+	if f.Dock, err = DecodeDock(prefs); err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", path)
+	}
+	return f, nil
+}
+
+// dockGUIDReader is the source of random GUIDs
+var dockGUIDReader io.Reader = rand.Reader
+
+// newDockGUID returns a random 32-bit GUID, as the Dock uses, that is neither
+// zero nor in used, and adds it to used
+func newDockGUID(used map[int64]bool) (int64, error) {
+	var b [4]byte
+	for {
+		if _, err := io.ReadFull(dockGUIDReader, b[:]); err != nil {
+			return 0, errors.Wrap(err, "failed to generate GUID")
+		}
+		guid := int64(binary.BigEndian.Uint32(b[:]))
+		if guid != 0 && !used[guid] {
+			used[guid] = true
+			return guid, nil
+		}
+	}
+}
+
+// Save writes the Dock back to the file, atomically, if it changed, and
+// reports whether it did. Like the Dock, it gives a GUID not used by other
+// tiles to tiles without one, bumps "mod-count" and sets "version" in new
+// files.
+func (f *DockFile) Save() (bool, error) {
+	patch, err := Diff(f.Dock.Raw, f.Dock.Encode())
+	if err != nil {
+		return false, err
+	}
+	if len(patch) == 0 {
+		return false, nil
+	}
+
+	sections := [][]DockTile{f.Dock.Apps, f.Dock.Others}
+	used := map[int64]bool{}
+	for _, tiles := range sections {
+		for _, t := range tiles {
+			used[t.Info().GUID] = true
+		}
+	}
+	for _, tiles := range sections {
+		for _, t := range tiles {
+			if t.Info().GUID == 0 {
+				if t.Info().GUID, err = newDockGUID(used); err != nil {
+					return false, err
+				}
+			}
+		}
+	}
+	prefs := f.Dock.Encode()
+	var count int64
+	if v, err := ValueOf(prefs["mod-count"]); err == nil {
+		if i, ok := v.(Integer); ok {
+			count, _ = i.Int64()
+		}
+	}
+	prefs["mod-count"] = count + 1
+	if _, ok := prefs["version"]; !ok {
+		prefs["version"] = int64(1)
+	}
+
+	data, err := Encode(prefs, f.Format)
+	if err != nil {
+		return false, err
+	}
+	if err := writeFileAtomic(f.Path, data, 0600); err != nil {
+		return false, errors.Wrapf(err, "failed to write %s", f.Path)
+	}
+	f.Dock.Raw = prefs
+	return true, nil
+}
@@ -0,0 +1,168 @@
+package cf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cf-dock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, format := range []Format{XMLFormat, BinaryFormat} {
+		path := filepath.Join(dir, format.String()+".plist")
+		data, err := Encode(dockPrefs(), format)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+		f, err := OpenDockFile(path)
+		require.NoError(t, err)
+		require.Equal(t, format, f.Format)
+		require.Len(t, f.Dock.Apps, 4)
+
+		// nothing to write
+		changed, err := f.Save()
+		require.NoError(t, err)
+		require.False(t, changed)
+		after, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, data, after)
+
+		changed, err = f.Dock.Add(DockApps, &AppTile{Label: "Mail", BundleID: "com.apple.mail",
+			URL: "file:///System/Applications/Mail.app/"}, DockPosition{Index: DockEnd})
+		require.NoError(t, err)
+		require.True(t, changed)
+		changed, err = f.Save()
+		require.NoError(t, err)
+		require.True(t, changed)
+
+		f, err = OpenDockFile(path)
+		require.NoError(t, err)
+		require.Equal(t, format, f.Format)
+		require.Equal(t, []string{"Safari", "spacer-tile", "small-spacer-tile", "flex-spacer-tile", "Mail"}, dockLabels(f.Dock.Apps))
+		require.NotZero(t, f.Dock.Apps[4].Info().GUID)
+		require.Equal(t, int64(48), f.Dock.Raw["tilesize"])
+		require.Equal(t, int64(13), f.Dock.Raw["mod-count"])
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0644), fi.Mode().Perm())
+	}
+}
+
+func TestDockFileNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cf-dock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, filepath.FromSlash(DockFilePath))
+	f, err := OpenDockFile(path)
+	require.NoError(t, err)
+	require.Equal(t, BinaryFormat, f.Format)
+	require.Empty(t, f.Dock.Apps)
+	require.Empty(t, f.Dock.Others)
+
+	changed, err := f.Save()
+	require.NoError(t, err)
+	require.False(t, changed)
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	_, err = f.Dock.Add(DockOthers, &URLTile{Label: "Go", URL: "https://go.dev/"}, DockPosition{Index: DockEnd})
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	changed, err = f.Save()
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	r, err := os.Open(path)
+	require.NoError(t, err)
+	defer r.Close()
+	v, format, err := Decode(r)
+	require.NoError(t, err)
+	require.Equal(t, BinaryFormat, format)
+	prefs := v.(map[string]interface{})
+	require.Equal(t, int64(1), prefs["mod-count"])
+	require.Equal(t, int64(1), prefs["version"])
+	require.Len(t, prefs["persistent-others"], 1)
+}
+
+func TestDockFileGUIDs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cf-dock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the random source yields zero, the GUID of Safari twice, then 7 and 8
+	reader := dockGUIDReader
+	defer func() { dockGUIDReader = reader }()
+	dockGUIDReader = bytes.NewReader([]byte{0, 0, 0, 0, 0, 0, 0, 5, 0, 0, 0, 5, 0, 0, 0, 7, 0, 0, 0, 8})
+
+	f, err := OpenDockFile(filepath.Join(dir, "com.apple.dock.plist"))
+	require.NoError(t, err)
+	safari := &AppTile{Label: "Safari", BundleID: "com.apple.Safari"}
+	safari.GUID = 5
+	f.Dock.Apps = []DockTile{safari, &AppTile{Label: "Mail", BundleID: "com.apple.mail"}}
+	f.Dock.Others = []DockTile{&URLTile{Label: "Go", URL: "https://go.dev/"}}
+	changed, err := f.Save()
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, int64(5), f.Dock.Apps[0].Info().GUID)
+	require.Equal(t, int64(7), f.Dock.Apps[1].Info().GUID)
+	require.Equal(t, int64(8), f.Dock.Others[0].Info().GUID)
+
+	// running out of randomness is an error
+	f.Dock.Apps = append(f.Dock.Apps, &SpacerTile{})
+	_, err = f.Save()
+	require.Error(t, err)
+}
+
+func TestDockFileOffline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cf-dock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the applications are on the system the Dock is for, not on this one
+	f, err := OpenDockFile(filepath.Join(dir, "com.apple.dock.plist"))
+	require.NoError(t, err)
+	for _, path := range []string{"/Applications/Missing Example.app", "/Users/Shared/Missing Example/"} {
+		tile, err := NewDockTile(path)
+		require.NoError(t, err)
+		section := DockApps
+		if _, ok := tile.(*FolderTile); ok {
+			section = DockOthers
+		}
+		changed, err := f.Dock.Add(section, tile, DockPosition{Index: DockEnd})
+		require.NoError(t, err)
+		require.True(t, changed)
+	}
+	changed, err := f.Save()
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	f, err = OpenDockFile(f.Path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Missing Example"}, dockLabels(f.Dock.Apps))
+	require.Equal(t, "file:///Applications/Missing%20Example.app/", f.Dock.Apps[0].(*AppTile).URL)
+	require.Len(t, f.Dock.Others, 1)
+	require.Equal(t, "file:///Users/Shared/Missing%20Example/", f.Dock.Others[0].(*FolderTile).URL)
+}
+
+func TestDockFileErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cf-dock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "array.plist")
+	require.NoError(t, ioutil.WriteFile(path, []byte(xmlPlistHeader+"<array/>"+xmlPlistFooter), 0644))
+	_, err = OpenDockFile(path)
+	require.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("garbage"), 0644))
+	_, err = OpenDockFile(path)
+	require.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package cf
+
+import "fmt"
+
+// Orientation is the screen edge the Dock is on. The values are the codes of
+// CoreDockGetOrientationAndPinning and CoreDockSetOrientationAndPinning; String
+// gives the "orientation" value of com.apple.dock.
+type Orientation int
+
+const (
+	OrientationBottom Orientation = 2
+	OrientationLeft   Orientation = 3
+	OrientationRight  Orientation = 4
+)
+
+func (o Orientation) String() string {
+	switch o {
+	case OrientationBottom:
+		return "bottom"
+	case OrientationLeft:
+		return "left"
+	case OrientationRight:
+		return "right"
+	}
+	return fmt.Sprintf("Orientation(%d)", int(o))
+}
+
+// Validate returns an error if o is not one of the Orientation constants.
+func (o Orientation) Validate() error {
+	if o < OrientationBottom || o > OrientationRight {
+		return fmt.Errorf("plist: invalid Dock orientation %d", int(o))
+	}
+	return nil
+}
+
+// ParseOrientation parses the names String returns.
+func ParseOrientation(s string) (Orientation, error) {
+	for o := OrientationBottom; o <= OrientationRight; o++ {
+		if s == o.String() {
+			return o, nil
+		}
+	}
+	return 0, fmt.Errorf("plist: unknown Dock orientation %q", s)
+}
+
+// Pinning is where the Dock is along its screen edge. The values are the codes
+// of CoreDockGetOrientationAndPinning and CoreDockSetOrientationAndPinning;
+// String gives the "pinning" value of com.apple.dock.
+type Pinning int
+
+const (
+	PinningStart  Pinning = 1
+	PinningMiddle Pinning = 2
+	PinningEnd    Pinning = 3
+)
+
+func (p Pinning) String() string {
+	switch p {
+	case PinningStart:
+		return "start"
+	case PinningMiddle:
+		return "middle"
+	case PinningEnd:
+		return "end"
+	}
+	return fmt.Sprintf("Pinning(%d)", int(p))
+}
+
+// Validate returns an error if p is not one of the Pinning constants.
+func (p Pinning) Validate() error {
+	if p < PinningStart || p > PinningEnd {
+		return fmt.Errorf("plist: invalid Dock pinning %d", int(p))
+	}
+	return nil
+}
+
+// ParsePinning parses the names String returns.
+func ParsePinning(s string) (Pinning, error) {
+	for p := PinningStart; p <= PinningEnd; p++ {
+		if s == p.String() {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("plist: unknown Dock pinning %q", s)
+}
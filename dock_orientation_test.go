@@ -0,0 +1,38 @@
+package cf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrientation(t *testing.T) {
+	for s, o := range map[string]Orientation{"bottom": OrientationBottom, "left": OrientationLeft, "right": OrientationRight} {
+		parsed, err := ParseOrientation(s)
+		require.NoError(t, err)
+		require.Equal(t, o, parsed)
+		require.Equal(t, s, o.String())
+		require.NoError(t, o.Validate())
+	}
+	require.Equal(t, 2, int(OrientationBottom))
+	_, err := ParseOrientation("top")
+	require.Error(t, err)
+	require.Error(t, Orientation(1).Validate())
+	require.Error(t, Orientation(5).Validate())
+	require.Equal(t, "Orientation(0)", Orientation(0).String())
+}
+
+func TestPinning(t *testing.T) {
+	for s, p := range map[string]Pinning{"start": PinningStart, "middle": PinningMiddle, "end": PinningEnd} {
+		parsed, err := ParsePinning(s)
+		require.NoError(t, err)
+		require.Equal(t, p, parsed)
+		require.Equal(t, s, p.String())
+		require.NoError(t, p.Validate())
+	}
+	require.Equal(t, 2, int(PinningMiddle))
+	_, err := ParsePinning("Middle")
+	require.Error(t, err)
+	require.Error(t, Pinning(0).Validate())
+	require.Error(t, Pinning(4).Validate())
+}
@@ -0,0 +1,225 @@
+package cf
+
+import "fmt"
+
+// DockDomain is the preferences domain of the Dock.
+var DockDomain = UserDomain("com.apple.dock")
+
+const (
+	// DockTileSizeMin and DockTileSizeMax bound "tilesize" and "largesize", in
+	// points
+	DockTileSizeMin = 16
+	DockTileSizeMax = 128
+)
+
+// MinimizeEffect is the animation of minimizing windows: the "mineffect" key.
+type MinimizeEffect string
+
+const (
+	MinimizeEffectGenie MinimizeEffect = "genie"
+	MinimizeEffectScale MinimizeEffect = "scale"
+	MinimizeEffectSuck  MinimizeEffect = "suck"
+)
+
+// DockSettings is the appearance and behaviour of the Dock, as set in the Dock
+// pane of System Settings. Each field is a key of DockDomain.
+type DockSettings struct {
+	// TileSize is "tilesize", the size of icons in points
+	TileSize float64
+	// Magnification is "magnification"
+	Magnification bool
+	// LargeSize is "largesize", the size of magnified icons in points
+	LargeSize float64
+	// Autohide is "autohide"
+	Autohide bool
+	// AutohideDelay is "autohide-delay", the seconds before the Dock shows
+	AutohideDelay float64
+	// AutohideTimeModifier is "autohide-time-modifier", the seconds the Dock
+	// takes to show and hide
+	AutohideTimeModifier float64
+	// MinimizeEffect is "mineffect"
+	MinimizeEffect MinimizeEffect
+	// MinimizeToApplication is "minimize-to-application"
+	MinimizeToApplication bool
+	// ShowProcessIndicators is "show-process-indicators"
+	ShowProcessIndicators bool
+	// ShowRecents is "show-recents"
+	ShowRecents bool
+	// StaticOnly is "static-only", showing only open applications
+	StaticOnly bool
+	// LaunchAnimation is "launchanim"
+	LaunchAnimation bool
+}
+
+// DefaultDockSettings returns the settings the Dock uses for keys that are
+// not set.
+func DefaultDockSettings() DockSettings {
+	return DockSettings{
+		TileSize:              48,
+		LargeSize:             DockTileSizeMax,
+		AutohideDelay:         0.5,
+		AutohideTimeModifier:  1,
+		MinimizeEffect:        MinimizeEffectGenie,
+		ShowProcessIndicators: true,
+		ShowRecents:           true,
+		LaunchAnimation:       true,
+	}
+}
+
+// InvalidDockSettingError is returned for Dock settings out of their range.
+type InvalidDockSettingError struct {
+	Key    string
+	Value  interface{}
+	Reason string
+}
+
+func (e *InvalidDockSettingError) Error() string {
+	return fmt.Sprintf("plist: invalid Dock setting %s %v: %s", e.Key, e.Value, e.Reason)
+}
+
+func validateDockTileSize(key string, size float64) error {
+	if !(size >= DockTileSizeMin && size <= DockTileSizeMax) {
+		return &InvalidDockSettingError{key, size,
+			fmt.Sprintf("not between %d and %d", DockTileSizeMin, DockTileSizeMax)}
+	}
+	return nil
+}
+
+// NormalizedTileSize returns TileSize scaled to 0–1, as CoreDockGetTileSize
+// and CoreDockSetTileSize take it.
+func (s DockSettings) NormalizedTileSize() float64 {
+	return (s.TileSize - DockTileSizeMin) / (DockTileSizeMax - DockTileSizeMin)
+}
+
+// DockTileSize converts a tile size scaled to 0–1 to points.
+func DockTileSize(normalized float64) (float64, error) {
+	if !(normalized >= 0 && normalized <= 1) {
+		return 0, &InvalidDockSettingError{"tilesize", normalized, "normalized size not between 0 and 1"}
+	}
+	return DockTileSizeMin + normalized*(DockTileSizeMax-DockTileSizeMin), nil
+}
+
+// Validate returns an *InvalidDockSettingError for the first field out of its
+// range. LargeSize is only checked if Magnification is on, as the Dock ignores
+// it otherwise.
+func (s DockSettings) Validate() error {
+	if err := validateDockTileSize("tilesize", s.TileSize); err != nil {
+		return err
+	}
+	if s.Magnification {
+		if err := validateDockTileSize("largesize", s.LargeSize); err != nil {
+			return err
+		}
+	}
+	if !(s.AutohideDelay >= 0) {
+		return &InvalidDockSettingError{"autohide-delay", s.AutohideDelay, "negative"}
+	}
+	if !(s.AutohideTimeModifier >= 0) {
+		return &InvalidDockSettingError{"autohide-time-modifier", s.AutohideTimeModifier, "negative"}
+	}
+	switch s.MinimizeEffect {
+	case MinimizeEffectGenie, MinimizeEffectScale, MinimizeEffectSuck:
+	default:
+		return &InvalidDockSettingError{"mineffect", s.MinimizeEffect, "unknown effect"}
+	}
+	return nil
+}
+
+// ReadDockSettings reads the settings from DockDomain in store, or with the
+// package-level Preferences functions if store is nil. Keys that are not set
+// have the values of DefaultDockSettings. It fails if a key has the wrong type
+// or a value Validate rejects.
+func ReadDockSettings(store Store) (DockSettings, error) {
+	t := TypedDomain{DockDomain, store}
+	s := DefaultDockSettings()
+	var err error
+	float := func(key string, v *float64) {
+		if err == nil {
+			*v, err = t.GetFloat64(key, *v)
+		}
+	}
+	boolean := func(key string, v *bool) {
+		if err == nil {
+			*v, err = t.GetBool(key, *v)
+		}
+	}
+	float("tilesize", &s.TileSize)
+	boolean("magnification", &s.Magnification)
+	float("largesize", &s.LargeSize)
+	boolean("autohide", &s.Autohide)
+	float("autohide-delay", &s.AutohideDelay)
+	float("autohide-time-modifier", &s.AutohideTimeModifier)
+	boolean("minimize-to-application", &s.MinimizeToApplication)
+	boolean("show-process-indicators", &s.ShowProcessIndicators)
+	boolean("show-recents", &s.ShowRecents)
+	boolean("static-only", &s.StaticOnly)
+	boolean("launchanim", &s.LaunchAnimation)
+	if err != nil {
+		return DockSettings{}, err
+	}
+	effect, err := t.GetString("mineffect", string(s.MinimizeEffect))
+	if err != nil {
+		return DockSettings{}, err
+	}
+	s.MinimizeEffect = MinimizeEffect(effect)
+	if err := s.Validate(); err != nil {
+		return DockSettings{}, err
+	}
+	return s, nil
+}
+
+// Values returns the settings as keys of DockDomain. "largesize" is left out
+// if Magnification is off and LargeSize is out of range, as Validate allows
+// then, so that turning magnification on later does not find an invalid size.
+func (s DockSettings) Values() map[string]interface{} {
+	values := map[string]interface{}{
+		"tilesize":                s.TileSize,
+		"magnification":           s.Magnification,
+		"largesize":               s.LargeSize,
+		"autohide":                s.Autohide,
+		"autohide-delay":          s.AutohideDelay,
+		"autohide-time-modifier":  s.AutohideTimeModifier,
+		"mineffect":               string(s.MinimizeEffect),
+		"minimize-to-application": s.MinimizeToApplication,
+		"show-process-indicators": s.ShowProcessIndicators,
+		"show-recents":            s.ShowRecents,
+		"static-only":             s.StaticOnly,
+		"launchanim":              s.LaunchAnimation,
+	}
+	if validateDockTileSize("largesize", s.LargeSize) != nil {
+		delete(values, "largesize")
+	}
+	return values
+}
+
+// Apply validates the settings and writes the ones that differ from
+// ReadDockSettings to DockDomain in store, or with the package-level
+// Preferences functions if store is nil, and synchronizes the domain. If the
+// current settings cannot be read, all of them are written. Nothing is written
+// if a setting is invalid or none changed. The running Dock reads the settings
+// when it is restarted.
+func (s DockSettings) Apply(store Store) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	if store == nil {
+		store = PreferencesStore{}
+	}
+	values := s.Values()
+	if current, err := ReadDockSettings(store); err == nil {
+		for key, v := range current.Values() {
+			if values[key] == v {
+				delete(values, key)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	d := DockDomain
+	if err := store.SetMulti(values, d.Application, d.User, d.Host); err != nil {
+		return err
+	}
+	_, err := store.Synchronize(d.Application, d.User, d.Host)
+	return err
+}
@@ -0,0 +1,124 @@
+package cf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockSettings(t *testing.T) {
+	store := &MemoryStore{}
+	d := DockDomain
+
+	s, err := ReadDockSettings(store)
+	require.NoError(t, err)
+	require.Equal(t, DefaultDockSettings(), s)
+
+	// as `defaults write` without a type flag writes them
+	require.NoError(t, store.SetMulti(map[string]interface{}{
+		"tilesize":       int64(72),
+		"autohide":       "YES",
+		"autohide-delay": "0",
+		"mineffect":      "scale",
+		"show-recents":   int64(0),
+	}, d.Application, d.User, d.Host))
+	s, err = ReadDockSettings(store)
+	require.NoError(t, err)
+	require.Equal(t, 72.0, s.TileSize)
+	require.Equal(t, 0.5, s.NormalizedTileSize())
+	require.True(t, s.Autohide)
+	require.Equal(t, 0.0, s.AutohideDelay)
+	require.Equal(t, MinimizeEffectScale, s.MinimizeEffect)
+	require.False(t, s.ShowRecents)
+	require.True(t, s.LaunchAnimation)
+
+	// only the changed settings are written
+	s.TileSize = 36
+	s.Magnification = true
+	s.StaticOnly = true
+	store.ResetWrites()
+	require.NoError(t, s.Apply(store))
+	require.ElementsMatch(t, []MemoryWrite{
+		{"tilesize", 36.0, d.Application, d.User, d.Host},
+		{"magnification", true, d.Application, d.User, d.Host},
+		{"static-only", true, d.Application, d.User, d.Host},
+	}, store.Writes())
+	require.Equal(t, 1, store.SynchronizeCount(d.Application, d.User, d.Host))
+	values, err := store.GetMulti(nil, d.Application, d.User, d.Host)
+	require.NoError(t, err)
+	require.Len(t, values, 7)
+	require.Equal(t, "scale", values["mineffect"])
+	read, err := ReadDockSettings(store)
+	require.NoError(t, err)
+	require.Equal(t, s, read)
+
+	// nothing changed: nothing is written or synchronized
+	store.ResetWrites()
+	require.NoError(t, read.Apply(store))
+	require.Empty(t, store.Writes())
+	require.Equal(t, 1, store.SynchronizeCount(d.Application, d.User, d.Host))
+
+	require.NoError(t, store.Set("largesize", "huge", d.Application, d.User, d.Host))
+	_, err = ReadDockSettings(store)
+	require.IsType(t, &PreferenceTypeError{}, err)
+	require.NoError(t, store.Set("largesize", int64(256), d.Application, d.User, d.Host))
+	_, err = ReadDockSettings(store)
+	require.IsType(t, &InvalidDockSettingError{}, err)
+
+	// settings that cannot be read are all written
+	store.ResetWrites()
+	require.NoError(t, s.Apply(store))
+	require.Len(t, store.Writes(), 12)
+	read, err = ReadDockSettings(store)
+	require.NoError(t, err)
+	require.Equal(t, s, read)
+}
+
+func TestDockSettingsValidate(t *testing.T) {
+	for _, f := range []func(*DockSettings){
+		func(s *DockSettings) { s.TileSize = 15 },
+		func(s *DockSettings) { s.TileSize = 129 },
+		func(s *DockSettings) { s.Magnification, s.LargeSize = true, 0 },
+		func(s *DockSettings) { s.AutohideDelay = -1 },
+		func(s *DockSettings) { s.AutohideTimeModifier = -0.5 },
+		func(s *DockSettings) { s.MinimizeEffect = "" },
+		func(s *DockSettings) { s.MinimizeEffect = "bounce" },
+	} {
+		s := DefaultDockSettings()
+		f(&s)
+		require.IsType(t, &InvalidDockSettingError{}, s.Validate())
+
+		// nothing is written
+		store := &MemoryStore{}
+		require.Error(t, s.Apply(store))
+		keys, err := store.KeyList(DockDomain.Application, DockDomain.User, DockDomain.Host)
+		require.NoError(t, err)
+		require.Empty(t, keys)
+	}
+	require.NoError(t, DefaultDockSettings().Validate())
+
+	// largesize only matters with magnification, and is then not written
+	s := DockSettings{TileSize: 48, MinimizeEffect: MinimizeEffectGenie}
+	require.NoError(t, s.Validate())
+	require.NotContains(t, s.Values(), "largesize")
+	store := &MemoryStore{}
+	require.NoError(t, s.Apply(store))
+	v, err := store.Get("largesize", DockDomain.Application, DockDomain.User, DockDomain.Host)
+	require.NoError(t, err)
+	require.Nil(t, v)
+	s.LargeSize = 64
+	require.Equal(t, 64.0, s.Values()["largesize"])
+}
+
+func TestDockTileSize(t *testing.T) {
+	for normalized, size := range map[float64]float64{0: 16, 0.5: 72, 1: 128} {
+		s, err := DockTileSize(normalized)
+		require.NoError(t, err)
+		require.Equal(t, size, s)
+		require.Equal(t, normalized, DockSettings{TileSize: size}.NormalizedTileSize())
+	}
+	_, err := DockTileSize(1.5)
+	require.Error(t, err)
+	_, err = DockTileSize(-0.1)
+	require.Error(t, err)
+}
@@ -0,0 +1,176 @@
+package cf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// dockPrefs is a trimmed com.apple.dock domain
+func dockPrefs() map[string]interface{} {
+	return map[string]interface{}{
+		"persistent-apps": []interface{}{
+			map[string]interface{}{
+				"GUID":      int64(3142237915),
+				"tile-type": "file-tile",
+				"tile-data": map[string]interface{}{
+					"file-label":        "Safari",
+					"bundle-identifier": "com.apple.Safari",
+					"file-data": map[string]interface{}{
+						"_CFURLString":     "file:///Applications/Safari.app/",
+						"_CFURLStringType": int64(15),
+					},
+					"file-type":     int64(41),
+					"file-mod-date": int64(3719845213),
+					"book":          []byte{0x62, 0x6f, 0x6f, 0x6b},
+					"dock-extra":    false,
+				},
+			},
+			map[string]interface{}{
+				"GUID":      int64(1),
+				"tile-type": "spacer-tile",
+				"tile-data": map[string]interface{}{},
+			},
+			map[string]interface{}{
+				"GUID":      int64(2),
+				"tile-type": "small-spacer-tile",
+				"tile-data": map[string]interface{}{},
+			},
+			map[string]interface{}{
+				"GUID":      int64(3),
+				"tile-type": "flex-spacer-tile",
+				"tile-data": map[string]interface{}{},
+			},
+		},
+		"persistent-others": []interface{}{
+			map[string]interface{}{
+				"GUID":      int64(4),
+				"tile-type": "directory-tile",
+				"tile-data": map[string]interface{}{
+					"file-label": "Downloads",
+					"file-data": map[string]interface{}{
+						"_CFURLString":     "file:///Users/me/Downloads/",
+						"_CFURLStringType": int64(15),
+					},
+					"file-type":         int64(2),
+					"displayas":         int64(1),
+					"showas":            int64(2),
+					"arrangement":       int64(2),
+					"preferreditemsize": int64(-1),
+				},
+			},
+			map[string]interface{}{
+				"GUID":      int64(5),
+				"tile-type": "url-tile",
+				"tile-data": map[string]interface{}{
+					"label": "Go",
+					"url": map[string]interface{}{
+						"_CFURLString":     "https://go.dev/",
+						"_CFURLStringType": int64(15),
+					},
+				},
+			},
+			map[string]interface{}{
+				"tile-type": "recents-tile",
+				"tile-data": map[string]interface{}{"list-type": int64(1)},
+			},
+		},
+		"tilesize":  int64(48),
+		"mod-count": int64(12),
+	}
+}
+
+func TestDecodeDock(t *testing.T) {
+	dock, err := DecodeDock(dockPrefs())
+	require.NoError(t, err)
+	require.Len(t, dock.Apps, 4)
+	require.Len(t, dock.Others, 3)
+
+	app := dock.Apps[0].(*AppTile)
+	require.Equal(t, int64(3142237915), app.GUID)
+	require.Equal(t, "Safari", app.Label)
+	require.Equal(t, "com.apple.Safari", app.BundleID)
+	require.Equal(t, "file:///Applications/Safari.app/", app.URL)
+	require.IsType(t, &SpacerTile{}, dock.Apps[1])
+	require.IsType(t, &SmallSpacerTile{}, dock.Apps[2])
+	require.IsType(t, &FlexSpacerTile{}, dock.Apps[3])
+
+	folder := dock.Others[0].(*FolderTile)
+	require.Equal(t, "Downloads", folder.Label)
+	require.Equal(t, "file:///Users/me/Downloads/", folder.URL)
+	require.Equal(t, FolderDisplayFolder, folder.Display)
+	require.Equal(t, FolderViewGrid, folder.View)
+	require.Equal(t, FolderArrangementDateAdded, folder.Arrangement)
+	require.Equal(t, "folder grid dateadded", folder.Display.String()+" "+folder.View.String()+" "+folder.Arrangement.String())
+
+	url := dock.Others[1].(*URLTile)
+	require.Equal(t, "Go", url.Label)
+	require.Equal(t, "https://go.dev/", url.URL)
+	require.Equal(t, "recents-tile", dock.Others[2].TileType())
+
+	// decoding and encoding gives back the exact dictionaries
+	require.Equal(t, dockPrefs(), dock.Encode())
+
+	// edits keep the keys the model does not know
+	app.Label = "Safari Technology Preview"
+	folder.View = FolderViewList
+	prefs := dock.Encode()
+	v, err := ParseKeyPath(":persistent-apps:0:tile-data:file-label").Get(prefs)
+	require.NoError(t, err)
+	require.Equal(t, "Safari Technology Preview", v)
+	v, err = ParseKeyPath(":persistent-apps:0:tile-data:book").Get(prefs)
+	require.NoError(t, err)
+	require.Equal(t, []byte("book"), v)
+	v, err = ParseKeyPath(":persistent-others:0:tile-data:showas").Get(prefs)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), v)
+}
+
+func TestEncodeDockTile(t *testing.T) {
+	require.Equal(t, map[string]interface{}{
+		"tile-type": "file-tile",
+		"tile-data": map[string]interface{}{
+			"file-label":        "Mail",
+			"bundle-identifier": "com.apple.mail",
+			"file-data": map[string]interface{}{
+				"_CFURLString":     "file:///System/Applications/Mail.app/",
+				"_CFURLStringType": int64(15),
+			},
+			"file-type": int64(41),
+		},
+	}, EncodeDockTile(&AppTile{Label: "Mail", BundleID: "com.apple.mail", URL: "file:///System/Applications/Mail.app/"}))
+
+	require.Equal(t, map[string]interface{}{
+		"tile-type": "directory-tile",
+		"tile-data": map[string]interface{}{
+			"file-label": "Applications",
+			"file-data": map[string]interface{}{
+				"_CFURLString":     "file:///Applications/",
+				"_CFURLStringType": int64(15),
+			},
+			"file-type":   int64(2),
+			"displayas":   int64(1),
+			"arrangement": int64(1),
+		},
+	}, EncodeDockTile(&FolderTile{Label: "Applications", URL: "file:///Applications/",
+		Display: FolderDisplayFolder, Arrangement: FolderArrangementName}))
+
+	require.Equal(t, map[string]interface{}{
+		"GUID":      int64(7),
+		"tile-type": "spacer-tile",
+		"tile-data": map[string]interface{}{},
+	}, EncodeDockTile(&SpacerTile{DockTileInfo{GUID: 7}}))
+}
+
+func TestDecodeDockTileErrors(t *testing.T) {
+	_, err := DecodeDockTile("x")
+	require.IsType(t, &InvalidDockTileError{}, err)
+	_, err = DecodeDockTile(map[string]interface{}{"tile-type": "file-tile", "tile-data": "x"})
+	require.IsType(t, &InvalidDockTileError{}, err)
+	_, err = DecodeDockTile(map[string]interface{}{"tile-type": "file-tile", "GUID": "x"})
+	require.IsType(t, &InvalidDockTileError{}, err)
+	_, err = DecodeDock(map[string]interface{}{"persistent-apps": []interface{}{"x"}})
+	require.Error(t, err)
+	_, err = DecodeDock(map[string]interface{}{"persistent-others": "x"})
+	require.Error(t, err)
+}
@@ -0,0 +1,200 @@
+package cf
+
+import (
+	"fmt"
+	"os"
+)
+
+// Domain names a preference domain: an application ID (or
+// PreferencesAnyApplication), a user name (PreferencesCurrentUser,
+// PreferencesAnyUser or the name of a user) and a host name
+// (PreferencesCurrentHost or PreferencesAnyHost).
+type Domain struct {
+	Application string `json:"application"`
+	User        string `json:"user"`
+	Host        string `json:"host"`
+}
+
+// UserDomain is the domain `defaults` reads and writes for app.
+func UserDomain(app string) Domain {
+	return Domain{app, PreferencesCurrentUser, PreferencesAnyHost}
+}
+
+// ByHostDomain is the domain `defaults -currentHost` reads and writes for app.
+func ByHostDomain(app string) Domain {
+	return Domain{app, PreferencesCurrentUser, PreferencesCurrentHost}
+}
+
+// GlobalDomain is the domain `defaults` calls NSGlobalDomain, shared by all
+// applications of the current user.
+func GlobalDomain() Domain {
+	return Domain{PreferencesAnyApplication, PreferencesCurrentUser, PreferencesAnyHost}
+}
+
+// SystemDomain is the domain of app shared by all users, in
+// /Library/Preferences. Writing to it requires root.
+func SystemDomain(app string) Domain {
+	return Domain{app, PreferencesAnyUser, PreferencesAnyHost}
+}
+
+func (d Domain) String() string {
+	return fmt.Sprintf("{%s %s %s}", d.Application, d.User, d.Host)
+}
+
+// InvalidDomainError is returned for domains that do not name a preference
+// domain, or that the process has no access to.
+type InvalidDomainError struct {
+	Domain Domain
+	Reason string
+}
+
+func (e *InvalidDomainError) Error() string {
+	return "invalid preferences domain " + e.Domain.String() + ": " + e.Reason
+}
+
+// Validate checks that the fields of d hold the right kind of names, and that
+// the application ID and user name are usable as file names. It also
+// rejects PreferencesAnyUser with PreferencesCurrentHost, which CFPreferences
+// does not support. (PreferencesAnyApplication with PreferencesCurrentHost is
+// the by-host global domain of the current user, which is legal.)
+func (d Domain) Validate() error {
+	fail := func(format string, args ...interface{}) error {
+		return &InvalidDomainError{d, fmt.Sprintf(format, args...)}
+	}
+	switch d.Application {
+	case "":
+		return fail("empty application ID")
+	case PreferencesCurrentUser, PreferencesAnyUser, PreferencesCurrentHost, PreferencesAnyHost:
+		return fail("%s is not an application ID", d.Application)
+	}
+	if err := checkPreferencesName("application ID", d.Application); err != nil {
+		return fail("%v", err)
+	}
+	switch d.User {
+	case "":
+		return fail("empty user name")
+	case PreferencesAnyApplication, PreferencesCurrentHost, PreferencesAnyHost:
+		return fail("%s is not a user name", d.User)
+	}
+	if err := checkPreferencesName("user name", d.User); err != nil {
+		return fail("%v", err)
+	}
+	switch d.Host {
+	case PreferencesCurrentHost, PreferencesAnyHost:
+	default:
+		return fail("host must be %s or %s", PreferencesCurrentHost, PreferencesAnyHost)
+	}
+	if d.User == PreferencesAnyUser && d.Host == PreferencesCurrentHost {
+		return fail("%s cannot be combined with %s", PreferencesAnyUser, PreferencesCurrentHost)
+	}
+	return nil
+}
+
+// preferencesIsRoot reports whether the process may write to the domains of
+// all users, and of users other than the current one
+var preferencesIsRoot = func() bool {
+	return os.Geteuid() == 0
+}
+
+// validateWrite checks the domain, and that the process may write to it
+func (d Domain) validateWrite() error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	if d.User != PreferencesCurrentUser && !preferencesIsRoot() {
+		return &InvalidDomainError{d, "writing to the preferences of other users requires root"}
+	}
+	return nil
+}
+
+// validateStoreWrite is validateWrite for the domain in store. Only the
+// package-level Preferences functions need root for the domains of other
+// users; other stores decide for themselves.
+func (d Domain) validateStoreWrite(store Store) error {
+	if _, ok := store.(PreferencesStore); ok {
+		return d.validateWrite()
+	}
+	return d.Validate()
+}
+
+// Value is Preferences for the domain.
+func (d Domain) Value(key string) (interface{}, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return Preferences(key, d.Application, d.User, d.Host)
+}
+
+// Set is PreferencesSet for the domain.
+func (d Domain) Set(key string, value interface{}) error {
+	if err := d.validateWrite(); err != nil {
+		return err
+	}
+	return PreferencesSet(key, value, d.Application, d.User, d.Host)
+}
+
+// SetMulti is PreferencesSetMulti for the domain.
+func (d Domain) SetMulti(keys map[string]interface{}) error {
+	if err := d.validateWrite(); err != nil {
+		return err
+	}
+	return PreferencesSetMulti(keys, d.Application, d.User, d.Host)
+}
+
+// Remove is PreferencesRemove for the domain.
+func (d Domain) Remove(key string) (bool, error) {
+	if err := d.validateWrite(); err != nil {
+		return false, err
+	}
+	return PreferencesRemove(key, d.Application, d.User, d.Host)
+}
+
+// RemoveAll is PreferencesRemoveDomain for the domain.
+func (d Domain) RemoveAll(removeFile bool) (bool, error) {
+	if err := d.validateWrite(); err != nil {
+		return false, err
+	}
+	return PreferencesRemoveDomain(d.Application, d.User, d.Host, removeFile)
+}
+
+// Synchronize is PreferencesSynchronize for the domain.
+func (d Domain) Synchronize() (bool, error) {
+	if err := d.Validate(); err != nil {
+		return false, err
+	}
+	return PreferencesSynchronize(d.Application, d.User, d.Host)
+}
+
+// KeyList is PreferencesKeyList for the domain.
+func (d Domain) KeyList() ([]string, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return PreferencesKeyList(d.Application, d.User, d.Host)
+}
+
+// Values is PreferencesMulti for the domain: the values of keys, or of all keys
+// if none are given.
+func (d Domain) Values(keys ...string) (map[string]interface{}, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return PreferencesMulti(keys, d.Application, d.User, d.Host)
+}
+
+// Domains returns the domains of all applications that have preferences for
+// the user and host, including the global domain.
+func Domains(userName, hostName string) ([]Domain, error) {
+	if err := (Domain{PreferencesAnyApplication, userName, hostName}).Validate(); err != nil {
+		return nil, err
+	}
+	apps, err := PreferencesApplicationList(userName, hostName)
+	if err != nil {
+		return nil, err
+	}
+	domains := make([]Domain, len(apps))
+	for i, app := range apps {
+		domains[i] = Domain{app, userName, hostName}
+	}
+	return domains, nil
+}
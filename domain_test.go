@@ -0,0 +1,69 @@
+package cf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainConstructors(t *testing.T) {
+	require.Equal(t, Domain{"com.apple.dock", PreferencesCurrentUser, PreferencesAnyHost}, UserDomain("com.apple.dock"))
+	require.Equal(t, Domain{"com.apple.dock", PreferencesCurrentUser, PreferencesCurrentHost}, ByHostDomain("com.apple.dock"))
+	require.Equal(t, Domain{PreferencesAnyApplication, PreferencesCurrentUser, PreferencesAnyHost}, GlobalDomain())
+	require.Equal(t, Domain{"com.apple.loginwindow", PreferencesAnyUser, PreferencesAnyHost}, SystemDomain("com.apple.loginwindow"))
+}
+
+func TestDomainValidate(t *testing.T) {
+	for _, d := range []Domain{
+		UserDomain("com.apple.dock"),
+		ByHostDomain("com.apple.dock"),
+		GlobalDomain(),
+		SystemDomain("com.apple.dock"),
+		{PreferencesAnyApplication, PreferencesCurrentUser, PreferencesCurrentHost},
+		{"com.apple.dock", "alice", PreferencesAnyHost},
+	} {
+		require.NoError(t, d.Validate(), d.String())
+	}
+
+	for _, d := range []Domain{
+		{},
+		{"", PreferencesCurrentUser, PreferencesAnyHost},
+		{"com.apple.dock", "", PreferencesAnyHost},
+		{"com.apple.dock", PreferencesCurrentUser, ""},
+		{"com.apple.dock", PreferencesCurrentUser, "host.local"},
+		{"com.apple.dock", PreferencesCurrentHost, PreferencesAnyHost},
+		{PreferencesCurrentUser, "com.apple.dock", PreferencesAnyHost},
+		{"com.apple.dock", PreferencesAnyHost, PreferencesCurrentUser},
+		{"com.apple.dock", PreferencesAnyUser, PreferencesCurrentHost},
+		{PreferencesAnyApplication, PreferencesAnyUser, PreferencesCurrentHost},
+		{"../../x", PreferencesCurrentUser, PreferencesAnyHost},
+		{"..", PreferencesCurrentUser, PreferencesAnyHost},
+		{`com.example\x`, PreferencesCurrentUser, PreferencesAnyHost},
+		{"com.apple.dock", "../root", PreferencesAnyHost},
+		{"com.apple.dock", ".", PreferencesAnyHost},
+		{"com.apple.dock", "a\x00", PreferencesAnyHost},
+	} {
+		err := d.Validate()
+		require.IsType(t, &InvalidDomainError{}, err, d.String())
+	}
+}
+
+func TestDomainWriteAccess(t *testing.T) {
+	isRoot := preferencesIsRoot
+	defer func() { preferencesIsRoot = isRoot }()
+	preferencesIsRoot = func() bool { return false }
+
+	require.IsType(t, &InvalidDomainError{}, SystemDomain("com.example").Set("a", "b"))
+	require.IsType(t, &InvalidDomainError{}, Domain{"com.example", "alice", PreferencesAnyHost}.SetMulti(nil))
+	require.IsType(t, &InvalidDomainError{}, Domain{"com.example", PreferencesAnyUser, PreferencesCurrentHost}.Set("a", "b"))
+	_, err := SystemDomain("com.example").Remove("a")
+	require.IsType(t, &InvalidDomainError{}, err)
+	_, err = SystemDomain("com.example").RemoveAll(true)
+	require.IsType(t, &InvalidDomainError{}, err)
+	_, err = ByHostDomain("").Value("a")
+	require.IsType(t, &InvalidDomainError{}, err)
+	_, err = ByHostDomain("").Synchronize()
+	require.IsType(t, &InvalidDomainError{}, err)
+	_, err = ByHostDomain("").KeyList()
+	require.IsType(t, &InvalidDomainError{}, err)
+}
@@ -2,8 +2,6 @@ package cf
 
 // Taken from go-osx-plist (see LICENSE), heavily adapted
 
-// #include <CoreFoundation/CoreFoundation.h>
-import "C"
 import (
 	"reflect"
 	"strconv"
@@ -17,17 +15,6 @@ func (e *UnsupportedTypeError) Error() string {
 	return "plist: unsupported type: " + e.Type.String()
 }
 
-type UnknownCFTypeError struct {
-	CFTypeID C.CFTypeID
-}
-
-func (e *UnknownCFTypeError) Error() string {
-	cfStr := C.CFCopyTypeIDDescription(e.CFTypeID)
-	str := stringRef(cfStr).Goize()
-	Release(cfStr)
-	return "plist: unknown CFTypeID " + strconv.Itoa(int(e.CFTypeID)) + " (" + str + ")"
-}
-
 type UnsupportedValueError struct {
 	Value reflect.Value
 	Str   string
@@ -44,3 +31,14 @@ type UnsupportedKeyTypeError struct {
 func (e *UnsupportedKeyTypeError) Error() string {
 	return "plist: unexpected dictionary key CFTypeID " + strconv.Itoa(e.CFTypeID)
 }
+
+// OverflowError is returned when an integer does not fit in the type it is
+// converted to.
+type OverflowError struct {
+	Value string
+	Type  string
+}
+
+func (e *OverflowError) Error() string {
+	return "plist: integer " + e.Value + " overflows " + e.Type
+}
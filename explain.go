@@ -0,0 +1,113 @@
+package cf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PreferencesSearchList returns the domains PreferencesApp looks a key up in
+// after the managed preferences, most specific first: the by-host and user
+// domains of the application, the by-host and user global domains, and the
+// domains shared by all users.
+func PreferencesSearchList(appID string) []Domain {
+	list := []Domain{
+		{appID, PreferencesCurrentUser, PreferencesCurrentHost},
+		{appID, PreferencesCurrentUser, PreferencesAnyHost},
+		{PreferencesAnyApplication, PreferencesCurrentUser, PreferencesCurrentHost},
+		{PreferencesAnyApplication, PreferencesCurrentUser, PreferencesAnyHost},
+		{appID, PreferencesAnyUser, PreferencesAnyHost},
+		{PreferencesAnyApplication, PreferencesAnyUser, PreferencesAnyHost},
+	}
+	if appID != PreferencesAnyApplication {
+		return list
+	}
+	// the global domains are only listed once
+	return []Domain{list[2], list[3], list[5]}
+}
+
+// Explanation tells where the value of a preference an application sees comes
+// from.
+type Explanation struct {
+	Key         string
+	Application string
+	// Value is the value the application sees, as returned by PreferencesApp
+	Value interface{}
+	// Forced is set if the value is managed, e.g. by a configuration profile.
+	// Managed values override the search list and cannot be changed by the
+	// application or the user.
+	Forced bool
+	// Source is the domain of the search list the value comes from, if it is
+	// not forced and is set
+	Source *Domain
+	// Layers holds the value of the key in every domain of the search list
+	Layers []ExplanationLayer
+}
+
+// ExplanationLayer is the value of a key in one domain of the search list, nil
+// if it is not set there.
+type ExplanationLayer struct {
+	Domain Domain
+	Value  interface{}
+}
+
+// Explain looks key up the way PreferencesApp does and reports what every
+// layer of the search list holds for it. A value that "won't stick" is either
+// forced, or set in the domain the application writes to while the application
+// reads a more specific one.
+func Explain(key, appID string) (*Explanation, error) {
+	e := &Explanation{Key: key, Application: appID}
+	var err error
+	if e.Value, err = PreferencesApp(key, appID); err != nil {
+		return nil, err
+	}
+	if e.Forced, err = PreferencesAppValueIsForced(key, appID); err != nil {
+		return nil, err
+	}
+	for _, d := range PreferencesSearchList(appID) {
+		v, err := Preferences(key, d.Application, d.User, d.Host)
+		if err != nil {
+			return nil, err
+		}
+		e.Layers = append(e.Layers, ExplanationLayer{d, v})
+		if v != nil && e.Source == nil && !e.Forced {
+			d := d
+			e.Source = &d
+		}
+	}
+	return e, nil
+}
+
+// String formats the explanation for people, one layer per line, with values
+// in the JSON form of property lists:
+//
+//	autohide in com.apple.dock: true
+//	  managed: true (forced)
+//	  {com.apple.dock kCFPreferencesCurrentUser kCFPreferencesCurrentHost}: not set
+//	  {com.apple.dock kCFPreferencesCurrentUser kCFPreferencesAnyHost}: false (shadowed)
+//	  ...
+func (e *Explanation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s in %s: %s\n", e.Key, e.Application, formatExplanationValue(e.Value))
+	if e.Forced {
+		fmt.Fprintf(&sb, "  managed: %s (forced)\n", formatExplanationValue(e.Value))
+	}
+	for _, l := range e.Layers {
+		note := ""
+		switch {
+		case l.Value == nil:
+		case e.Source != nil && *e.Source == l.Domain:
+			note = " (effective)"
+		default:
+			note = " (shadowed)"
+		}
+		fmt.Fprintf(&sb, "  %s: %s%s\n", l.Domain, formatExplanationValue(l.Value), note)
+	}
+	return sb.String()
+}
+
+func formatExplanationValue(v interface{}) string {
+	if v == nil {
+		return "not set"
+	}
+	return formatJSONPlistValue(v)
+}
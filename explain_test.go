@@ -0,0 +1,112 @@
+//go:build !darwin
+// +build !darwin
+
+package cf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreferencesSearchList(t *testing.T) {
+	require.Len(t, PreferencesSearchList("com.example"), 6)
+	require.Equal(t, []Domain{
+		{PreferencesAnyApplication, PreferencesCurrentUser, PreferencesCurrentHost},
+		GlobalDomain(),
+		SystemDomain(PreferencesAnyApplication),
+	}, PreferencesSearchList(PreferencesAnyApplication))
+	for _, d := range PreferencesSearchList("com.example") {
+		require.NoError(t, d.Validate())
+	}
+}
+
+func TestPreferencesApp(t *testing.T) {
+	root := withPreferencesRoot(t)
+
+	v, err := PreferencesApp("a", "com.example")
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	// each layer overrides the ones after it in the search list
+	for _, layer := range []struct {
+		domain Domain
+		value  string
+	}{
+		{SystemDomain(PreferencesAnyApplication), "system global"},
+		{SystemDomain("com.example"), "system"},
+		{GlobalDomain(), "global"},
+	} {
+		d := layer.domain
+		require.NoError(t, PreferencesSet("a", layer.value, d.Application, d.User, d.Host))
+		v, err := PreferencesApp("a", "com.example")
+		require.NoError(t, err)
+		require.Equal(t, layer.value, v)
+	}
+
+	require.NoError(t, UserDomain("com.example").Set("a", "user"))
+	require.NoError(t, ByHostDomain("com.example").Set("a", "byhost"))
+	v, err = PreferencesApp("a", "com.example")
+	require.NoError(t, err)
+	require.Equal(t, "byhost", v)
+	forced, err := PreferencesAppValueIsForced("a", "com.example")
+	require.NoError(t, err)
+	require.False(t, forced)
+
+	managed := filepath.Join(root, "Library", "Managed Preferences", "me", "com.example.plist")
+	require.NoError(t, os.MkdirAll(filepath.Dir(managed), 0755))
+	require.NoError(t, ioutil.WriteFile(managed, []byte(`{"a": "managed"}`), 0644))
+	v, err = PreferencesApp("a", "com.example")
+	require.NoError(t, err)
+	require.Equal(t, "managed", v)
+	forced, err = PreferencesAppValueIsForced("a", "com.example")
+	require.NoError(t, err)
+	require.True(t, forced)
+
+	ok, err := PreferencesAppSynchronize("com.example")
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, err = PreferencesApp("a", "")
+	require.Error(t, err)
+}
+
+func TestExplain(t *testing.T) {
+	root := withPreferencesRoot(t)
+
+	e, err := Explain("autohide", "com.apple.dock")
+	require.NoError(t, err)
+	require.Nil(t, e.Value)
+	require.Nil(t, e.Source)
+	require.Len(t, e.Layers, 6)
+
+	require.NoError(t, UserDomain("com.apple.dock").Set("autohide", false))
+	require.NoError(t, ByHostDomain("com.apple.dock").Set("autohide", true))
+	e, err = Explain("autohide", "com.apple.dock")
+	require.NoError(t, err)
+	require.Equal(t, true, e.Value)
+	require.False(t, e.Forced)
+	require.Equal(t, ByHostDomain("com.apple.dock"), *e.Source)
+	require.Equal(t, false, e.Layers[1].Value)
+	require.Equal(t, `autohide in com.apple.dock: true
+  {com.apple.dock kCFPreferencesCurrentUser kCFPreferencesCurrentHost}: true (effective)
+  {com.apple.dock kCFPreferencesCurrentUser kCFPreferencesAnyHost}: false (shadowed)
+  {kCFPreferencesAnyApplication kCFPreferencesCurrentUser kCFPreferencesCurrentHost}: not set
+  {kCFPreferencesAnyApplication kCFPreferencesCurrentUser kCFPreferencesAnyHost}: not set
+  {com.apple.dock kCFPreferencesAnyUser kCFPreferencesAnyHost}: not set
+  {kCFPreferencesAnyApplication kCFPreferencesAnyUser kCFPreferencesAnyHost}: not set
+`, e.String())
+
+	managed := filepath.Join(root, "Library", "Managed Preferences", "com.apple.dock.plist")
+	require.NoError(t, os.MkdirAll(filepath.Dir(managed), 0755))
+	require.NoError(t, ioutil.WriteFile(managed, []byte(`{"autohide": false}`), 0644))
+	e, err = Explain("autohide", "com.apple.dock")
+	require.NoError(t, err)
+	require.Equal(t, false, e.Value)
+	require.True(t, e.Forced)
+	require.Nil(t, e.Source)
+	require.Contains(t, e.String(), "  managed: false (forced)\n")
+	require.Contains(t, e.String(), "kCFPreferencesCurrentHost}: true (shadowed)\n")
+}
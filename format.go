@@ -0,0 +1,114 @@
+package cf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Format is a property list file format.
+type Format int
+
+const (
+	// BinaryFormat is the "bplist00" format
+	BinaryFormat Format = iota + 1
+	// XMLFormat is the plist-1.0 DTD XML format
+	XMLFormat
+	// OpenStepFormat is the old-style ASCII format, including .strings files
+	OpenStepFormat
+	// JSONFormat is the JSON form of property lists, see EncodeJSONPlist
+	JSONFormat
+)
+
+func (f Format) String() string {
+	switch f {
+	case BinaryFormat:
+		return "binary"
+	case XMLFormat:
+		return "xml"
+	case OpenStepFormat:
+		return "openstep"
+	case JSONFormat:
+		return "json"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// UnknownFormatError is returned when a format is not one of the Format
+// constants.
+type UnknownFormatError struct {
+	Format Format
+}
+
+func (e *UnknownFormatError) Error() string {
+	return "plist: unknown format " + e.Format.String()
+}
+
+// DetectFormat guesses the format of a property list from its first bytes:
+// the "bplist00" magic, an XML declaration or element, or JSON. Everything else,
+// including data starting with "{" or "(" that is not valid JSON, is taken to
+// be OpenStep.
+func DetectFormat(data []byte) Format {
+	if bytes.HasPrefix(data, []byte(bplistMagic)) {
+		return BinaryFormat
+	}
+	text := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(text, []byte("<?xml")), bytes.HasPrefix(text, []byte("<!DOCTYPE")),
+		bytes.HasPrefix(text, []byte("<plist")):
+		return XMLFormat
+	case len(text) > 0 && text[0] != '(' && text[0] != '<' && json.Valid(text):
+		// "<00>" is OpenStep data, and "(" never starts JSON
+		return JSONFormat
+	}
+	return OpenStepFormat
+}
+
+// Decode reads a property list in any of the supported formats and returns
+// the same Go values typeRef.Goize does, along with the format it found, so
+// that the data can be written back with Encode in its original format.
+func Decode(r io.Reader) (interface{}, Format, error) {
+	v, format, err := DecodeValue(r)
+	if err != nil {
+		return nil, format, err
+	}
+	return v.Interface(), format, nil
+}
+
+// DecodeValue is Decode for Values.
+func DecodeValue(r io.Reader) (Value, Format, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	format := DetectFormat(data)
+	var v Value
+	switch format {
+	case BinaryFormat:
+		v, err = DecodeBinaryPlistValue(data)
+	case XMLFormat:
+		v, err = DecodeXMLPlistValue(data)
+	case JSONFormat:
+		v, err = DecodeJSONPlistValue(data)
+	default:
+		v, err = DecodeOpenStepPlistValue(data)
+	}
+	return v, format, err
+}
+
+// Encode encodes v in the given format.
+func Encode(v interface{}, format Format) ([]byte, error) {
+	switch format {
+	case BinaryFormat:
+		return EncodeBinaryPlist(v)
+	case XMLFormat:
+		return EncodeXMLPlist(v)
+	case OpenStepFormat:
+		return EncodeOpenStepPlist(v)
+	case JSONFormat:
+		return EncodeJSONPlist(v)
+	}
+	return nil, &UnknownFormatError{format}
+}
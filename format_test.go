@@ -0,0 +1,49 @@
+package cf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectFormat(t *testing.T) {
+	for data, format := range map[string]Format{
+		"bplist00\xd0\x08":               BinaryFormat,
+		"<?xml version=\"1.0\"?>":        XMLFormat,
+		"\xef\xbb\xbf\n<!DOCTYPE plist>": XMLFormat,
+		"<plist version=\"1.0\">":        XMLFormat,
+		"{\"a\": [1, 2]}":                JSONFormat,
+		" [\"a\"]":                       JSONFormat,
+		"{a = (1, 2);}":                  OpenStepFormat,
+		"(a, b)":                         OpenStepFormat,
+		"<00010203>":                     OpenStepFormat,
+		"\"key\" = \"value\";":           OpenStepFormat,
+		"/* comment */ {a = b;}":         OpenStepFormat,
+		"":                               OpenStepFormat,
+	} {
+		require.Equal(t, format, DetectFormat([]byte(data)), data)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	in := map[string]interface{}{"a": "b", "list": []interface{}{"c"}}
+	for _, format := range []Format{BinaryFormat, XMLFormat, OpenStepFormat, JSONFormat} {
+		data, err := Encode(in, format)
+		require.NoError(t, err)
+		v, detected, err := Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+		require.Equal(t, format, detected)
+		require.Equal(t, in, v)
+	}
+
+	_, format, err := Decode(strings.NewReader("<?xml version=\"1.0\"?><plist><dict><key>a</key></dict></plist>"))
+	require.Equal(t, XMLFormat, format)
+	require.IsType(t, &InvalidXMLPlistError{}, err)
+
+	_, err = Encode("a", Format(0))
+	require.IsType(t, &UnknownFormatError{}, err)
+	require.Equal(t, "plist: unknown format Format(0)", err.Error())
+	require.Equal(t, "openstep", OpenStepFormat.String())
+}
@@ -0,0 +1,367 @@
+package cf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSON form of property lists.
+//
+// Strings, booleans, arrays and dictionaries map to their JSON counterparts,
+// integers to JSON numbers and reals to JSON numbers that always have a
+// fraction or an exponent. Types JSON has no counterpart for are written as
+// objects with a single "$" key: {"$date": "<RFC 3339>"}, {"$data": "<base64>"},
+// {"$real": "nan"|"+infinity"|"-infinity"} and {"$uid": n}. A dictionary
+// that would be mistaken for one of these is wrapped as {"$dict": {...}}.
+
+const (
+	jsonPlistDate = "$date"
+	jsonPlistData = "$data"
+	jsonPlistReal = "$real"
+	jsonPlistUID  = "$uid"
+	jsonPlistDict = "$dict"
+)
+
+// InvalidJSONPlistError is returned when the JSON form of a property list is
+// malformed.
+type InvalidJSONPlistError struct {
+	Offset int64
+	Reason string
+}
+
+func (e *InvalidJSONPlistError) Error() string {
+	return fmt.Sprintf("plist: invalid JSON plist at offset %d: %s", e.Offset, e.Reason)
+}
+
+// EncodeJSONPlist encodes v in the JSON form of property lists, indented with
+// two spaces. Dictionary keys are sorted (or in Dict order).
+//
+// v may be any value Pool.Object accepts, and may contain Values.
+func EncodeJSONPlist(v interface{}) ([]byte, error) {
+	val, err := objectValue(v)
+	if err != nil {
+		return nil, err
+	}
+	compact := &bytes.Buffer{}
+	writeJSONPlistValue(compact, val)
+	buf := &bytes.Buffer{}
+	if err := json.Indent(buf, compact.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// DecodeJSONPlist decodes the JSON form of a property list into the Go values
+// DecodeXMLPlist returns.
+func DecodeJSONPlist(data []byte) (interface{}, error) {
+	v, err := DecodeJSONPlistValue(data)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// formatJSONPlistValue formats v in the compact JSON form of property lists,
+// for messages
+func formatJSONPlistValue(v interface{}) string {
+	val, err := ValueOf(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	buf := &bytes.Buffer{}
+	writeJSONPlistValue(buf, val)
+	return buf.String()
+}
+
+func writeJSONPlistValue(buf *bytes.Buffer, val Value) {
+	switch v := val.(type) {
+	case Bool:
+		if v {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case Integer:
+		buf.WriteString(formatInteger(v))
+	case Real:
+		if math.IsNaN(v.Value) || math.IsInf(v.Value, 0) {
+			writeJSONPlistTagged(buf, jsonPlistReal, formatXMLPlistReal(v.Value))
+			return
+		}
+		bits := 64
+		if v.Width == 4 {
+			bits = 32
+		}
+		s := strconv.FormatFloat(v.Value, 'g', -1, bits)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		buf.WriteString(s)
+	case Date:
+		writeJSONPlistTagged(buf, jsonPlistDate, v.UTC().Format(time.RFC3339Nano))
+	case Data:
+		writeJSONPlistTagged(buf, jsonPlistData, base64.StdEncoding.EncodeToString(v))
+	case String:
+		writeJSONPlistString(buf, string(v))
+	case UID:
+		buf.WriteString(`{"` + jsonPlistUID + `":` + strconv.FormatUint(uint64(v), 10) + "}")
+	case Array:
+		buf.WriteString("[")
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			writeJSONPlistValue(buf, elem)
+		}
+		buf.WriteString("]")
+	case Dict:
+		wrap := len(v) == 1 && strings.HasPrefix(v[0].Key, "$")
+		if wrap {
+			buf.WriteString(`{"` + jsonPlistDict + `":`)
+		}
+		buf.WriteString("{")
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			writeJSONPlistString(buf, e.Key)
+			buf.WriteString(":")
+			writeJSONPlistValue(buf, e.Value)
+		}
+		buf.WriteString("}")
+		if wrap {
+			buf.WriteString("}")
+		}
+	default:
+		panic("plist: unexpected Value type")
+	}
+}
+
+func writeJSONPlistTagged(buf *bytes.Buffer, tag, s string) {
+	buf.WriteString(`{"` + tag + `":`)
+	writeJSONPlistString(buf, s)
+	buf.WriteString("}")
+}
+
+func writeJSONPlistString(buf *bytes.Buffer, s string) {
+	// json.Marshal only fails on unsupported types
+	b, _ := json.Marshal(s)
+	buf.Write(b)
+}
+
+// DecodeJSONPlistValue decodes the JSON form of a property list into a Value,
+// keeping dictionary key order.
+func DecodeJSONPlistValue(data []byte) (Value, error) {
+	d := &jsonPlistDecoder{json.NewDecoder(bytes.NewReader(data))}
+	d.d.UseNumber()
+	tok, err := d.token()
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.value(tok)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.d.Token(); err != io.EOF {
+		return nil, d.fail("unexpected data after the top-level value")
+	}
+	return v, nil
+}
+
+type jsonPlistDecoder struct {
+	d *json.Decoder
+}
+
+func (d *jsonPlistDecoder) fail(format string, args ...interface{}) error {
+	return &InvalidJSONPlistError{Offset: d.d.InputOffset(), Reason: fmt.Sprintf(format, args...)}
+}
+
+func (d *jsonPlistDecoder) token() (json.Token, error) {
+	tok, err := d.d.Token()
+	if err == io.EOF {
+		return nil, d.fail("unexpected end of data")
+	}
+	if err != nil {
+		return nil, d.fail("%s", err)
+	}
+	return tok, nil
+}
+
+func (d *jsonPlistDecoder) value(tok json.Token) (Value, error) {
+	switch t := tok.(type) {
+	case string:
+		return String(t), nil
+	case bool:
+		return Bool(t), nil
+	case json.Number:
+		return parseJSONPlistNumber(d, string(t))
+	case json.Delim:
+		switch t {
+		case '[':
+			return d.array()
+		case '{':
+			return d.dict()
+		}
+	case nil:
+		return nil, d.fail("null is not a property list value")
+	}
+	return nil, d.fail("unexpected %v", tok)
+}
+
+func parseJSONPlistNumber(d *jsonPlistDecoder, s string) (Value, error) {
+	if strings.ContainsAny(s, ".eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, d.fail("invalid real %s", s)
+		}
+		return Real{f, 8}, nil
+	}
+	i, err := parseXMLPlistInteger(s)
+	if err != nil {
+		return nil, d.fail("invalid integer %s", s)
+	}
+	return i, nil
+}
+
+func (d *jsonPlistDecoder) array() (Array, error) {
+	out := Array{}
+	for {
+		tok, err := d.token()
+		if err != nil {
+			return nil, err
+		}
+		if tok == json.Delim(']') {
+			return out, nil
+		}
+		v, err := d.value(tok)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+}
+
+// dict reads an object, interpreting it as a tagged value if it is one
+func (d *jsonPlistDecoder) dict() (Value, error) {
+	_, value, err := d.object()
+	if err != nil {
+		return nil, err
+	}
+	return value()
+}
+
+// object reads an object and returns functions giving its entries and its
+// interpretation. They are deferred because an object under a "$dict" key is
+// taken literally if "$dict" is the only key of its parent, without
+// interpreting the object itself.
+func (d *jsonPlistDecoder) object() (func() (Dict, error), func() (Value, error), error) {
+	out := Dict{}
+	var wrappedLiteral func() (Dict, error)
+	var wrappedValue func() (Value, error)
+	for {
+		tok, err := d.token()
+		if err != nil {
+			return nil, nil, err
+		}
+		if tok == json.Delim('}') {
+			break
+		}
+		key := tok.(string)
+		if tok, err = d.token(); err != nil {
+			return nil, nil, err
+		}
+		if key == jsonPlistDict {
+			wrappedLiteral, wrappedValue = nil, nil
+			if tok == json.Delim('{') {
+				if wrappedLiteral, wrappedValue, err = d.object(); err != nil {
+					return nil, nil, err
+				}
+				out.Set(key, nil)
+				continue
+			}
+		}
+		v, err := d.value(tok)
+		if err != nil {
+			return nil, nil, err
+		}
+		out.Set(key, v)
+	}
+
+	literal := func() (Dict, error) {
+		if wrappedValue == nil {
+			return out, nil
+		}
+		v, err := wrappedValue()
+		if err != nil {
+			return nil, err
+		}
+		out.Set(jsonPlistDict, v)
+		return out, nil
+	}
+	value := func() (Value, error) {
+		if wrappedLiteral != nil && len(out) == 1 {
+			return wrappedLiteral()
+		}
+		dict, err := literal()
+		if err != nil {
+			return nil, err
+		}
+		return d.tagged(dict)
+	}
+	return literal, value, nil
+}
+
+// tagged interprets a dictionary with a single "$" key as the value it tags
+func (d *jsonPlistDecoder) tagged(out Dict) (Value, error) {
+	if len(out) != 1 || !strings.HasPrefix(out[0].Key, "$") {
+		return out, nil
+	}
+	tag, v := out[0].Key, out[0].Value
+	switch tag {
+	case jsonPlistDict:
+		if dict, ok := v.(Dict); ok {
+			return dict, nil
+		}
+	case jsonPlistUID:
+		if i, ok := v.(Integer); ok {
+			if u, ok := i.Uint64(); ok {
+				return UID(u), nil
+			}
+		}
+	case jsonPlistDate, jsonPlistData, jsonPlistReal:
+		s, ok := v.(String)
+		if !ok {
+			break
+		}
+		switch tag {
+		case jsonPlistDate:
+			if t, err := time.Parse(time.RFC3339Nano, string(s)); err == nil {
+				return Date{t}, nil
+			}
+		case jsonPlistData:
+			if data, err := base64.StdEncoding.DecodeString(string(s)); err == nil {
+				return Data(data), nil
+			}
+		case jsonPlistReal:
+			switch s {
+			case "nan":
+				return Real{math.NaN(), 8}, nil
+			case "+infinity":
+				return Real{math.Inf(1), 8}, nil
+			case "-infinity":
+				return Real{math.Inf(-1), 8}, nil
+			}
+		}
+	default:
+		return out, nil
+	}
+	return nil, d.fail("invalid %s value", tag)
+}
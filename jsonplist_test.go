@@ -0,0 +1,122 @@
+package cf
+
+import (
+	"math"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const jsonPlistFixture = `{
+  "$dollar": {
+    "$dict": {
+      "$x": 1
+    }
+  },
+  "data": {
+    "$data": "AAEC"
+  },
+  "date": {
+    "$date": "2020-01-02T03:04:05.5Z"
+  },
+  "empty": [],
+  "inf": {
+    "$real": "-infinity"
+  },
+  "list": [
+    "a",
+    true,
+    -1,
+    1.0,
+    0.25
+  ],
+  "uint": 18446744073709551615
+}
+`
+
+func TestEncodeJSONPlist(t *testing.T) {
+	out, err := EncodeJSONPlist(map[string]interface{}{
+		"$dollar": map[string]interface{}{"$x": 1},
+		"data":    []byte{0, 1, 2},
+		"date":    time.Date(2020, 1, 2, 3, 4, 5, 500000000, time.UTC),
+		"empty":   []string{},
+		"inf":     math.Inf(-1),
+		"list":    []interface{}{"a", true, -1, 1.0, float32(0.25)},
+		"uint":    uint64(math.MaxUint64),
+	})
+	require.NoError(t, err)
+	require.Equal(t, jsonPlistFixture, string(out))
+}
+
+func TestDecodeJSONPlist(t *testing.T) {
+	v, err := DecodeJSONPlist([]byte(jsonPlistFixture))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"$dollar": map[string]interface{}{"$x": int64(1)},
+		"data":    []byte{0, 1, 2},
+		"date":    time.Date(2020, 1, 2, 3, 4, 5, 500000000, time.UTC).Local(),
+		"empty":   []interface{}(nil),
+		"inf":     math.Inf(-1),
+		"list":    []interface{}{"a", true, int64(-1), 1.0, 0.25},
+		"uint":    uint64(math.MaxUint64),
+	}, v)
+
+	v, err = DecodeJSONPlistValue([]byte(`{"b": {"$uid": 3}, "a": {"$other": 1}}`))
+	require.NoError(t, err)
+	require.Equal(t, Dict{{"b", UID(3)}, {"a", Dict{{"$other", Int(1)}}}}, v)
+}
+
+func TestDecodeJSONPlistInvalid(t *testing.T) {
+	for _, data := range []string{
+		``,
+		`{"a": }`,
+		`{"a": null}`,
+		`[1] [2]`,
+		`{"$date": "yesterday"}`,
+		`{"$data": 1}`,
+		`{"$real": "1.5"}`,
+		`{"$uid": -1}`,
+		`99999999999999999999`,
+	} {
+		_, err := DecodeJSONPlist([]byte(data))
+		require.IsType(t, &InvalidJSONPlistError{}, err, data)
+	}
+}
+
+func TestJSONPlistTagKeys(t *testing.T) {
+	// user dictionaries that look like tagged values survive a round trip
+	for _, v := range []Value{
+		Dict{{"$date", String("hello")}},
+		Dict{{"$data", Int(1)}},
+		Dict{{"$real", String("nan")}},
+		Dict{{"$uid", Int(3)}},
+		Dict{{"$dict", Dict{{"a", Int(1)}}}},
+		Dict{{"$dict", Dict{}}},
+		Dict{{"$dict", Dict{{"$dict", Dict{{"$date", String("x")}}}}}},
+		Dict{{"$dict", Dict{{"$uid", Int(3)}}}, {"b", Bool(true)}},
+		Array{Dict{{"$dict", String("x")}}},
+	} {
+		data, err := EncodeJSONPlist(v)
+		require.NoError(t, err)
+		decoded, err := DecodeJSONPlistValue(data)
+		require.NoError(t, err, string(data))
+		require.Equal(t, v, decoded, string(data))
+	}
+}
+
+func TestJSONPlistArbitrary(t *testing.T) {
+	f := func(arb Arbitrary) interface{} { a, _ := standardize(arb.Value); return a }
+	g := func(arb Arbitrary) interface{} {
+		data, err := EncodeJSONPlist(arb.Value)
+		require.NoError(t, err)
+		val, err := DecodeJSONPlist(data)
+		require.NoError(t, err)
+		a, _ := standardize(val)
+		return a
+	}
+	if err := quick.CheckEqual(f, g, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,327 @@
+package cf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KeyPath addresses a node in a tree of map[string]interface{} and
+// []interface{} values, such as the ones Goize produces, one dictionary key or
+// array index per entry.
+//
+// Key paths are written the way PlistBuddy takes them: entries separated by
+// colons, with an optional leading colon, e.g. ":persistent-apps:3:tile-data".
+// A backslash escapes a colon or a backslash inside a key.
+type KeyPath []string
+
+// ParseKeyPath splits a PlistBuddy-style key path into its entries. The empty
+// path and ":" address the root.
+func ParseKeyPath(s string) KeyPath {
+	s = strings.TrimPrefix(s, ":")
+	if s == "" {
+		return KeyPath{}
+	}
+	path := KeyPath{}
+	var entry strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			entry.WriteByte(s[i])
+		case s[i] == ':':
+			path = append(path, entry.String())
+			entry.Reset()
+		default:
+			entry.WriteByte(s[i])
+		}
+	}
+	return append(path, entry.String())
+}
+
+func (p KeyPath) String() string {
+	var sb strings.Builder
+	for _, entry := range p {
+		sb.WriteString(":")
+		for i := 0; i < len(entry); i++ {
+			if entry[i] == ':' || entry[i] == '\\' {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(entry[i])
+		}
+	}
+	if sb.Len() == 0 {
+		return ":"
+	}
+	return sb.String()
+}
+
+// KeyPathNotFoundError is returned when an entry of a key path does not exist:
+// a missing dictionary key or an array index out of range.
+type KeyPathNotFoundError struct {
+	Path string
+}
+
+func (e *KeyPathNotFoundError) Error() string {
+	return "plist: " + e.Path + " does not exist"
+}
+
+// KeyPathExistsError is returned by Add and Copy when the destination is
+// already present.
+type KeyPathExistsError struct {
+	Path string
+}
+
+func (e *KeyPathExistsError) Error() string {
+	return "plist: " + e.Path + " already exists"
+}
+
+// KeyPathTypeError is returned when a key path goes through a node that cannot
+// be indexed with the next entry (a scalar value, or an array indexed with
+// something other than a number), and by Merge when the node at the key path
+// is not a container or does not match what is merged into it.
+type KeyPathTypeError struct {
+	Path  string
+	Value interface{}
+	// Entry is the key path entry that could not be looked up, if any
+	Entry string
+}
+
+func (e *KeyPathTypeError) Error() string {
+	if e.Entry == "" {
+		return fmt.Sprintf("plist: unexpected %T at %s", e.Value, e.Path)
+	}
+	return fmt.Sprintf("plist: cannot look up %q in %T at %s", e.Entry, e.Value, e.Path)
+}
+
+// Get returns the node of tree at p.
+func (p KeyPath) Get(tree interface{}) (interface{}, error) {
+	node := tree
+	for i := range p {
+		var err error
+		if node, err = lookup(node, p, i); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// Set replaces the existing node of tree at p with v.
+//
+// Like all mutating KeyPath methods, Set modifies maps in place and returns
+// the updated tree, which has to be used instead of tree as slices may have
+// been reallocated.
+func (p KeyPath) Set(tree interface{}, v interface{}) (interface{}, error) {
+	if len(p) == 0 {
+		return v, nil
+	}
+	return modify(tree, p, 0, func(container interface{}) (interface{}, error) {
+		key := p[len(p)-1]
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, ok := c[key]; !ok {
+				return nil, &KeyPathNotFoundError{p.String()}
+			}
+			c[key] = v
+			return c, nil
+		case []interface{}:
+			i, err := index(c, p, len(p)-1, false)
+			if err != nil {
+				return nil, err
+			}
+			c[i] = v
+			return c, nil
+		}
+		return nil, &KeyPathTypeError{p[:len(p)-1].String(), container, key}
+	})
+}
+
+// Add inserts v into tree at p, which must not exist yet. An array index
+// inserts before the element at that index; an index equal to the length of the
+// array, or an empty last entry, appends.
+func (p KeyPath) Add(tree interface{}, v interface{}) (interface{}, error) {
+	if len(p) == 0 {
+		return nil, &KeyPathExistsError{p.String()}
+	}
+	return modify(tree, p, 0, func(container interface{}) (interface{}, error) {
+		key := p[len(p)-1]
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, ok := c[key]; ok {
+				return nil, &KeyPathExistsError{p.String()}
+			}
+			c[key] = v
+			return c, nil
+		case []interface{}:
+			i := len(c)
+			if key != "" {
+				var err error
+				if i, err = index(c, p, len(p)-1, true); err != nil {
+					return nil, err
+				}
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = v
+			return c, nil
+		}
+		return nil, &KeyPathTypeError{p[:len(p)-1].String(), container, key}
+	})
+}
+
+// Delete removes the node of tree at p.
+func (p KeyPath) Delete(tree interface{}) (interface{}, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	return modify(tree, p, 0, func(container interface{}) (interface{}, error) {
+		key := p[len(p)-1]
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, ok := c[key]; !ok {
+				return nil, &KeyPathNotFoundError{p.String()}
+			}
+			delete(c, key)
+			return c, nil
+		case []interface{}:
+			i, err := index(c, p, len(p)-1, false)
+			if err != nil {
+				return nil, err
+			}
+			return append(c[:i], c[i+1:]...), nil
+		}
+		return nil, &KeyPathTypeError{p[:len(p)-1].String(), container, key}
+	})
+}
+
+// Copy adds a deep copy of the node of tree at p to tree at dst, with the
+// semantics of Add.
+func (p KeyPath) Copy(tree interface{}, dst KeyPath) (interface{}, error) {
+	v, err := p.Get(tree)
+	if err != nil {
+		return nil, err
+	}
+	return dst.Add(tree, deepCopy(v))
+}
+
+// Merge merges v into the node of tree at p the way PlistBuddy does: if the
+// node is a dictionary, the keys of the dictionary v that it does not have yet
+// are added to it; if it is an array, the elements of the array v, or v itself
+// if it is not an array, are appended to it. Merging does not recurse.
+func (p KeyPath) Merge(tree interface{}, v interface{}) (interface{}, error) {
+	node, err := p.Get(tree)
+	if err != nil {
+		return nil, err
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, &KeyPathTypeError{Path: p.String(), Value: node}
+		}
+		for key, val := range m {
+			if _, ok := n[key]; !ok {
+				n[key] = deepCopy(val)
+			}
+		}
+		return tree, nil
+	case []interface{}:
+		if a, ok := v.([]interface{}); ok {
+			for _, val := range a {
+				n = append(n, deepCopy(val))
+			}
+		} else {
+			n = append(n, deepCopy(v))
+		}
+		return p.Set(tree, n)
+	}
+	return nil, &KeyPathTypeError{Path: p.String(), Value: node}
+}
+
+// lookup returns the child of node addressed by p[i]
+func lookup(node interface{}, p KeyPath, i int) (interface{}, error) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		v, ok := n[p[i]]
+		if !ok {
+			return nil, &KeyPathNotFoundError{p[:i+1].String()}
+		}
+		return v, nil
+	case []interface{}:
+		idx, err := index(n, p, i, false)
+		if err != nil {
+			return nil, err
+		}
+		return n[idx], nil
+	}
+	return nil, &KeyPathTypeError{p[:i].String(), node, p[i]}
+}
+
+// index parses p[i] as an index into a, which may be one past the end if
+// insert is set
+func index(a []interface{}, p KeyPath, i int, insert bool) (int, error) {
+	idx, err := strconv.Atoi(p[i])
+	if err != nil {
+		return 0, &KeyPathTypeError{p[:i].String(), a, p[i]}
+	}
+	end := len(a)
+	if insert {
+		end++
+	}
+	if idx < 0 || idx >= end {
+		return 0, &KeyPathNotFoundError{p[:i+1].String()}
+	}
+	return idx, nil
+}
+
+// modify walks down to the parent of the last entry of p, replaces it with
+// what fn returns for it, and stores the updated containers back on the way up
+func modify(node interface{}, p KeyPath, i int, fn func(container interface{}) (interface{}, error)) (interface{}, error) {
+	if i == len(p)-1 {
+		return fn(node)
+	}
+	child, err := lookup(node, p, i)
+	if err != nil {
+		return nil, err
+	}
+	child, err = modify(child, p, i+1, fn)
+	if err != nil {
+		return nil, err
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		n[p[i]] = child
+	case []interface{}:
+		// lookup has validated the index
+		idx, _ := strconv.Atoi(p[i])
+		n[idx] = child
+	}
+	return node, nil
+}
+
+// deepCopy copies the maps and slices of a tree
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			out[key] = deepCopy(val)
+		}
+		return out
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = deepCopy(val)
+		}
+		return out
+	case []byte:
+		if v == nil {
+			return v
+		}
+		return append([]byte{}, v...)
+	}
+	return v
+}
@@ -0,0 +1,163 @@
+package cf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func keyPathTree() map[string]interface{} {
+	return map[string]interface{}{
+		"persistent-apps": []interface{}{
+			map[string]interface{}{"tile-data": map[string]interface{}{"file-label": "Safari"}},
+			map[string]interface{}{"tile-data": map[string]interface{}{"file-label": "Mail"}},
+		},
+		"NSUserKeyEquivalents": map[string]interface{}{"Print": "@p"},
+		"a:b":                  int64(1),
+		"empty":                []interface{}(nil),
+	}
+}
+
+func TestParseKeyPath(t *testing.T) {
+	require.Equal(t, KeyPath{}, ParseKeyPath(""))
+	require.Equal(t, KeyPath{}, ParseKeyPath(":"))
+	require.Equal(t, KeyPath{"a", "0", "b"}, ParseKeyPath(":a:0:b"))
+	require.Equal(t, KeyPath{"a", "0", "b"}, ParseKeyPath("a:0:b"))
+	require.Equal(t, KeyPath{"a:b", `c\`}, ParseKeyPath(`:a\:b:c\\`))
+	require.Equal(t, KeyPath{"a", ""}, ParseKeyPath(":a:"))
+
+	require.Equal(t, `:a\:b:c\\`, KeyPath{"a:b", `c\`}.String())
+	require.Equal(t, ":", KeyPath{}.String())
+}
+
+func TestKeyPathGet(t *testing.T) {
+	tree := keyPathTree()
+
+	v, err := ParseKeyPath(":persistent-apps:1:tile-data:file-label").Get(tree)
+	require.NoError(t, err)
+	require.Equal(t, "Mail", v)
+	v, err = ParseKeyPath(`:a\:b`).Get(tree)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), v)
+	v, err = ParseKeyPath(":").Get(tree)
+	require.NoError(t, err)
+	require.Equal(t, tree, v)
+
+	_, err = ParseKeyPath(":persistent-apps:2").Get(tree)
+	require.IsType(t, &KeyPathNotFoundError{}, err)
+	require.Equal(t, ":persistent-apps:2", err.(*KeyPathNotFoundError).Path)
+	_, err = ParseKeyPath(":missing:x").Get(tree)
+	require.IsType(t, &KeyPathNotFoundError{}, err)
+	require.Equal(t, ":missing", err.(*KeyPathNotFoundError).Path)
+	_, err = ParseKeyPath(":persistent-apps:first").Get(tree)
+	require.IsType(t, &KeyPathTypeError{}, err)
+	_, err = ParseKeyPath(`:a\:b:c`).Get(tree)
+	require.IsType(t, &KeyPathTypeError{}, err)
+	require.Equal(t, `:a\:b`, err.(*KeyPathTypeError).Path)
+}
+
+func TestKeyPathSet(t *testing.T) {
+	tree, err := ParseKeyPath(":NSUserKeyEquivalents:Print").Set(keyPathTree(), "@~p")
+	require.NoError(t, err)
+	v, err := ParseKeyPath(":NSUserKeyEquivalents:Print").Get(tree)
+	require.NoError(t, err)
+	require.Equal(t, "@~p", v)
+
+	tree, err = ParseKeyPath(":persistent-apps:0").Set(tree, "x")
+	require.NoError(t, err)
+	v, err = ParseKeyPath(":persistent-apps:0").Get(tree)
+	require.NoError(t, err)
+	require.Equal(t, "x", v)
+
+	_, err = ParseKeyPath(":NSUserKeyEquivalents:Copy").Set(tree, "@c")
+	require.IsType(t, &KeyPathNotFoundError{}, err)
+	_, err = ParseKeyPath(":empty:0").Set(tree, "x")
+	require.IsType(t, &KeyPathNotFoundError{}, err)
+
+	v, err = ParseKeyPath("").Set(tree, "root")
+	require.NoError(t, err)
+	require.Equal(t, "root", v)
+}
+
+func TestKeyPathAdd(t *testing.T) {
+	tree, err := ParseKeyPath(":empty:").Add(keyPathTree(), "a")
+	require.NoError(t, err)
+	tree, err = ParseKeyPath(":empty:0").Add(tree, "b")
+	require.NoError(t, err)
+	tree, err = ParseKeyPath(":empty:2").Add(tree, "c")
+	require.NoError(t, err)
+	tree, err = ParseKeyPath(":NSUserKeyEquivalents:Copy").Add(tree, "@c")
+	require.NoError(t, err)
+
+	v, err := ParseKeyPath(":empty").Get(tree)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"b", "a", "c"}, v)
+	v, err = ParseKeyPath(":NSUserKeyEquivalents").Get(tree)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"Print": "@p", "Copy": "@c"}, v)
+
+	_, err = ParseKeyPath(":NSUserKeyEquivalents:Copy").Add(tree, "@c")
+	require.IsType(t, &KeyPathExistsError{}, err)
+	_, err = ParseKeyPath(":empty:4").Add(tree, "d")
+	require.IsType(t, &KeyPathNotFoundError{}, err)
+	_, err = ParseKeyPath(`:a\:b:c`).Add(tree, "d")
+	require.IsType(t, &KeyPathTypeError{}, err)
+}
+
+func TestKeyPathDelete(t *testing.T) {
+	tree, err := ParseKeyPath(":persistent-apps:0").Delete(keyPathTree())
+	require.NoError(t, err)
+	tree, err = ParseKeyPath(":NSUserKeyEquivalents:Print").Delete(tree)
+	require.NoError(t, err)
+
+	v, err := ParseKeyPath(":persistent-apps:0:tile-data:file-label").Get(tree)
+	require.NoError(t, err)
+	require.Equal(t, "Mail", v)
+	v, err = ParseKeyPath(":NSUserKeyEquivalents").Get(tree)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{}, v)
+
+	_, err = ParseKeyPath(":persistent-apps:1").Delete(tree)
+	require.IsType(t, &KeyPathNotFoundError{}, err)
+	_, err = ParseKeyPath(":NSUserKeyEquivalents:Print").Delete(tree)
+	require.IsType(t, &KeyPathNotFoundError{}, err)
+}
+
+func TestKeyPathCopy(t *testing.T) {
+	tree, err := ParseKeyPath(":persistent-apps:0").Copy(keyPathTree(), ParseKeyPath(":persistent-apps:"))
+	require.NoError(t, err)
+
+	// the copy is independent of the original
+	tree, err = ParseKeyPath(":persistent-apps:2:tile-data:file-label").Set(tree, "Safari 2")
+	require.NoError(t, err)
+	v, err := ParseKeyPath(":persistent-apps:0:tile-data:file-label").Get(tree)
+	require.NoError(t, err)
+	require.Equal(t, "Safari", v)
+
+	_, err = ParseKeyPath(":persistent-apps:0").Copy(tree, ParseKeyPath(":NSUserKeyEquivalents:Print"))
+	require.IsType(t, &KeyPathExistsError{}, err)
+	_, err = ParseKeyPath(":missing").Copy(tree, ParseKeyPath(":copy"))
+	require.IsType(t, &KeyPathNotFoundError{}, err)
+}
+
+func TestKeyPathMerge(t *testing.T) {
+	tree, err := ParseKeyPath(":NSUserKeyEquivalents").Merge(keyPathTree(),
+		map[string]interface{}{"Print": "@~p", "Copy": "@c"})
+	require.NoError(t, err)
+	v, err := ParseKeyPath(":NSUserKeyEquivalents").Get(tree)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"Print": "@p", "Copy": "@c"}, v)
+
+	tree, err = ParseKeyPath(":empty").Merge(tree, []interface{}{"a", "b"})
+	require.NoError(t, err)
+	tree, err = ParseKeyPath(":empty").Merge(tree, "c")
+	require.NoError(t, err)
+	v, err = ParseKeyPath(":empty").Get(tree)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"a", "b", "c"}, v)
+
+	_, err = ParseKeyPath(":NSUserKeyEquivalents").Merge(tree, "x")
+	require.IsType(t, &KeyPathTypeError{}, err)
+	_, err = ParseKeyPath(`:a\:b`).Merge(tree, "x")
+	require.IsType(t, &KeyPathTypeError{}, err)
+}
@@ -0,0 +1,429 @@
+package cf
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Structs are converted to and from property list dictionaries field by field.
+// The key for a field is its name, or the name given in a `plist` struct tag:
+//
+//   Label   string    `plist:"file-label"`
+//   Size    int       `plist:",omitempty"`
+//   Ignored string    `plist:"-"`
+//
+// Fields with the omitempty option are left out when they hold false, 0, "",
+// a zero time.Time, a nil pointer or interface, or an empty slice, array or map.
+// Fields of untagged embedded structs (and pointers to structs) are treated as
+// fields of the outer struct; a field of the outer struct wins over a promoted
+// field with the same key.
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Marshal converts v into the property list value Pool.Object would build for
+// it, expressed in the types typeRef.Goize returns.
+func Marshal(v interface{}) (interface{}, error) {
+	val, err := objectValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return val.Interface(), nil
+}
+
+type plistField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var plistFieldCache sync.Map // map[reflect.Type][]plistField
+
+// structFields returns the encodable fields of struct type t
+func structFields(t reflect.Type) []plistField {
+	if fields, ok := plistFieldCache.Load(t); ok {
+		return fields.([]plistField)
+	}
+	fields := collectStructFields(t, nil, map[reflect.Type]bool{})
+
+	// keep the shallowest field for each key, the first one on a tie
+	seen := map[string]int{}
+	var out []plistField
+	for _, f := range fields {
+		if i, ok := seen[f.name]; ok {
+			if len(f.index) < len(out[i].index) {
+				out[i] = f
+			}
+			continue
+		}
+		seen[f.name] = len(out)
+		out = append(out, f)
+	}
+	plistFieldCache.Store(t, out)
+	return out
+}
+
+func collectStructFields(t reflect.Type, index []int, visited map[reflect.Type]bool) []plistField {
+	if visited[t] {
+		return nil
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	var fields []plistField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("plist")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if comma := strings.IndexByte(tag, ','); comma >= 0 {
+			name, opts = tag[:comma], tag[comma+1:]
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct && ft != timeType {
+			if sf.PkgPath != "" && sf.Type.Kind() == reflect.Ptr {
+				// can't be allocated when decoding
+				continue
+			}
+			fields = append(fields, collectStructFields(ft, fieldIndex, visited)...)
+			continue
+		}
+		if sf.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, plistField{
+			name:      name,
+			index:     fieldIndex,
+			omitEmpty: hasTagOption(opts, "omitempty"),
+		})
+	}
+	return fields
+}
+
+func hasTagOption(opts, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// structFieldValue returns the field of v at index, or false if the field is
+// in an embedded struct behind a nil pointer
+func structFieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// structEntries returns the keys and values to encode for struct value v,
+// skipping empty omitempty fields
+func structEntries(v reflect.Value) ([]string, []reflect.Value) {
+	var keys []string
+	var values []reflect.Value
+	for _, f := range structFields(v.Type()) {
+		fv, ok := structFieldValue(v, f.index)
+		if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		keys = append(keys, f.name)
+		values = append(values, fv)
+	}
+	return keys, values
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).IsZero()
+		}
+	}
+	return false
+}
+
+// UnmarshalTypeError is returned by Unmarshal when a property list value
+// cannot be stored in the Go value at Path.
+type UnmarshalTypeError struct {
+	Path  string
+	Value interface{}
+	Type  reflect.Type
+	Str   string
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = ":"
+	}
+	msg := fmt.Sprintf("plist: cannot unmarshal %T into Go value of type %s at %s", e.Value, e.Type, path)
+	if e.Str != "" {
+		msg += ": " + e.Str
+	}
+	return msg
+}
+
+// Unmarshal stores property list value v, as returned by typeRef.Goize or one of
+// the file decoders (or a Value), into the value pointed to by dst.
+//
+// Dictionaries are decoded into structs (see Marshal for the field mapping) or
+// string-keyed maps, arrays into slices and arrays, and numbers into any Go
+// numeric type that holds them exactly. Dictionary keys without a matching
+// struct field are ignored, as are nil values. Errors name the key path of the
+// offending value, e.g. ":persistent-apps:3:tile-data".
+func Unmarshal(v interface{}, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("plist: Unmarshal needs a non-nil pointer, got %T", dst)
+	}
+	if val, ok := v.(Value); ok {
+		v = val.Interface()
+	}
+	return unmarshalValue(v, rv.Elem(), "")
+}
+
+func unmarshalValue(v interface{}, dst reflect.Value, path string) error {
+	if v == nil {
+		return nil
+	}
+	mismatch := func(str string) error {
+		return &UnmarshalTypeError{Path: path, Value: v, Type: dst.Type(), Str: str}
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return unmarshalValue(v, dst.Elem(), path)
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			return mismatch("")
+		}
+		dst.Set(reflect.ValueOf(v))
+		return nil
+	case reflect.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return mismatch("")
+		}
+		dst.SetBool(b)
+		return nil
+	case reflect.String:
+		s, ok := v.(string)
+		if !ok {
+			return mismatch("")
+		}
+		dst.SetString(s)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := exactInt64(v)
+		if !ok {
+			return mismatch("")
+		}
+		if dst.OverflowInt(i) {
+			return mismatch(strconv.FormatInt(i, 10) + " overflows " + dst.Type().String())
+		}
+		dst.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u, ok := v.(uint64); ok {
+			if dst.OverflowUint(u) {
+				return mismatch(strconv.FormatUint(u, 10) + " overflows " + dst.Type().String())
+			}
+			dst.SetUint(u)
+			return nil
+		}
+		i, ok := exactInt64(v)
+		if !ok {
+			return mismatch("")
+		}
+		if i < 0 || dst.OverflowUint(uint64(i)) {
+			return mismatch(strconv.FormatInt(i, 10) + " overflows " + dst.Type().String())
+		}
+		dst.SetUint(uint64(i))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, ok := float64Value(v)
+		if !ok {
+			return mismatch("")
+		}
+		// values in range are rounded to float32, infinities kept
+		if !math.IsInf(f, 0) && dst.OverflowFloat(f) {
+			return mismatch(strconv.FormatFloat(f, 'g', -1, 64) + " overflows " + dst.Type().String())
+		}
+		dst.SetFloat(f)
+		return nil
+	case reflect.Struct:
+		if dst.Type() == timeType {
+			t, ok := v.(time.Time)
+			if !ok {
+				return mismatch("")
+			}
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch("")
+		}
+		for _, f := range structFields(dst.Type()) {
+			elem, ok := m[f.name]
+			if !ok || elem == nil {
+				continue
+			}
+			if err := unmarshalValue(elem, allocFieldByIndex(dst, f.index), path+":"+f.name); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if dst.Type().Key().Kind() != reflect.String {
+			return &UnsupportedTypeError{dst.Type()}
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch("")
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+		for k, elem := range m {
+			ev := reflect.New(dst.Type().Elem()).Elem()
+			if err := unmarshalValue(elem, ev, path+":"+k); err != nil {
+				return err
+			}
+			dst.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), ev)
+		}
+		return nil
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			if data, ok := v.([]byte); ok {
+				dst.SetBytes(append([]byte{}, data...))
+				return nil
+			}
+		}
+		a, ok := v.([]interface{})
+		if !ok {
+			return mismatch("")
+		}
+		out := reflect.MakeSlice(dst.Type(), len(a), len(a))
+		for i, elem := range a {
+			if err := unmarshalValue(elem, out.Index(i), path+":"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(out)
+		return nil
+	case reflect.Array:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			if data, ok := v.([]byte); ok {
+				if len(data) != dst.Len() {
+					return mismatch(fmt.Sprintf("%d bytes of data", len(data)))
+				}
+				reflect.Copy(dst, reflect.ValueOf(data))
+				return nil
+			}
+		}
+		a, ok := v.([]interface{})
+		if !ok {
+			return mismatch("")
+		}
+		if len(a) != dst.Len() {
+			return mismatch(fmt.Sprintf("array of %d elements", len(a)))
+		}
+		for i, elem := range a {
+			if err := unmarshalValue(elem, dst.Index(i), path+":"+strconv.Itoa(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return &UnsupportedTypeError{dst.Type()}
+}
+
+// allocFieldByIndex returns the field of v at index, allocating embedded
+// struct pointers on the way
+func allocFieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// exactInt64 returns v as an int64 if v is a number with an integral value
+// that fits
+func exactInt64(v interface{}) (int64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	}
+	return 0, false
+}
+
+// float64Value returns v as a float64 if v is a number
+func float64Value(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,179 @@
+package cf
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type marshalFileData struct {
+	URL  string `plist:"_CFURLString"`
+	Type int    `plist:"_CFURLStringType"`
+}
+
+type marshalTileData struct {
+	Label    string           `plist:"file-label"`
+	FileData *marshalFileData `plist:"file-data,omitempty"`
+	Modified time.Time        `plist:"file-mod-date,omitempty"`
+	Internal string           `plist:"-"`
+	hidden   int
+}
+
+type marshalCommon struct {
+	GUID int64
+	Type string `plist:"tile-type"`
+}
+
+type marshalTile struct {
+	marshalCommon
+	*MarshalExtra
+	TileData marshalTileData `plist:"tile-data"`
+	Type     string          `plist:"tile-type,omitempty"`
+}
+
+type MarshalExtra struct {
+	Hidden bool `plist:"is-hidden"`
+}
+
+func TestMarshal(t *testing.T) {
+	v, err := Marshal(marshalTile{
+		marshalCommon: marshalCommon{GUID: 42, Type: "ignored"},
+		TileData: marshalTileData{
+			Label:    "Safari",
+			FileData: &marshalFileData{URL: "file:///Applications/Safari.app/", Type: 15},
+			Internal: "x",
+			hidden:   1,
+		},
+		Type: "file-tile",
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"GUID":      int64(42),
+		"tile-type": "file-tile",
+		"tile-data": map[string]interface{}{
+			"file-label": "Safari",
+			"file-data": map[string]interface{}{
+				"_CFURLString":     "file:///Applications/Safari.app/",
+				"_CFURLStringType": int64(15),
+			},
+		},
+	}, v)
+
+	_, err = Marshal((*marshalTile)(nil))
+	require.IsType(t, &UnsupportedValueError{}, err)
+}
+
+func TestUnmarshal(t *testing.T) {
+	var tile marshalTile
+	err := Unmarshal(map[string]interface{}{
+		"GUID":      int32(42),
+		"tile-type": "file-tile",
+		"is-hidden": true,
+		"unknown":   "ignored",
+		"tile-data": map[string]interface{}{
+			"file-label":    "Safari",
+			"file-mod-date": time.Unix(1551675967, 0),
+			"file-data": map[string]interface{}{
+				"_CFURLString":     "file:///Applications/Safari.app/",
+				"_CFURLStringType": float64(15),
+			},
+		},
+	}, &tile)
+	require.NoError(t, err)
+	require.Equal(t, marshalTile{
+		marshalCommon: marshalCommon{GUID: 42},
+		MarshalExtra:  &MarshalExtra{Hidden: true},
+		TileData: marshalTileData{
+			Label:    "Safari",
+			FileData: &marshalFileData{URL: "file:///Applications/Safari.app/", Type: 15},
+			Modified: time.Unix(1551675967, 0),
+		},
+		Type: "file-tile",
+	}, tile)
+}
+
+func TestUnmarshalCollections(t *testing.T) {
+	var dst struct {
+		Apps  []string
+		Sizes map[string]uint8
+		Hash  [2]byte
+		Data  []byte
+		Any   interface{}
+		Ratio float32
+	}
+	err := Unmarshal(map[string]interface{}{
+		"Apps":  []interface{}{"Safari", "Mail"},
+		"Sizes": map[string]interface{}{"small": int64(16)},
+		"Hash":  []byte{1, 2},
+		"Data":  []byte{3},
+		"Any":   []interface{}{int64(1)},
+		"Ratio": int64(2),
+	}, &dst)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Safari", "Mail"}, dst.Apps)
+	require.Equal(t, map[string]uint8{"small": 16}, dst.Sizes)
+	require.Equal(t, [2]byte{1, 2}, dst.Hash)
+	require.Equal(t, []byte{3}, dst.Data)
+	require.Equal(t, []interface{}{int64(1)}, dst.Any)
+	require.Equal(t, float32(2), dst.Ratio)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var tile marshalTile
+	err := Unmarshal(map[string]interface{}{
+		"tile-data": map[string]interface{}{"file-label": int64(1)},
+	}, &tile)
+	require.IsType(t, &UnmarshalTypeError{}, err)
+	require.Equal(t, ":tile-data:file-label", err.(*UnmarshalTypeError).Path)
+
+	var apps []struct{ Size uint8 }
+	err = Unmarshal([]interface{}{map[string]interface{}{"Size": int64(1)},
+		map[string]interface{}{"Size": int64(300)}}, &apps)
+	require.IsType(t, &UnmarshalTypeError{}, err)
+	require.Equal(t, ":1:Size", err.(*UnmarshalTypeError).Path)
+
+	var u uint64
+	require.NoError(t, Unmarshal(uint64(math.MaxUint64), &u))
+	require.Equal(t, uint64(math.MaxUint64), u)
+	var u32 uint32
+	require.IsType(t, &UnmarshalTypeError{}, Unmarshal(uint64(math.MaxUint64), &u32))
+
+	var i int
+	require.IsType(t, &UnmarshalTypeError{}, Unmarshal(1.5, &i))
+	require.IsType(t, &UnmarshalTypeError{}, Unmarshal(uint64(math.MaxUint64), &i))
+	require.Error(t, Unmarshal(1, i))
+
+	var f32 float32
+	require.NoError(t, Unmarshal(0.5, &f32))
+	require.Equal(t, float32(0.5), f32)
+	require.IsType(t, &UnmarshalTypeError{}, Unmarshal(1e300, &f32))
+	require.IsType(t, &UnmarshalTypeError{}, Unmarshal(-1e300, &f32))
+	// other values are rounded, as encoding/json does
+	require.NoError(t, Unmarshal(0.1, &f32))
+	require.Equal(t, float32(0.1), f32)
+	require.NoError(t, Unmarshal(int64(1<<24+1), &f32))
+	require.Equal(t, float32(1<<24), f32)
+	require.NoError(t, Unmarshal(math.Inf(-1), &f32))
+	require.True(t, math.IsInf(float64(f32), -1))
+	var f64 float64
+	require.NoError(t, Unmarshal(1e300, &f64))
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	in := marshalTile{
+		marshalCommon: marshalCommon{GUID: -1},
+		TileData:      marshalTileData{Label: "Mail", Modified: time.Unix(1551675967, 0)},
+		Type:          "file-tile",
+	}
+	v, err := Marshal(in)
+	require.NoError(t, err)
+	data, err := EncodeBinaryPlist(v)
+	require.NoError(t, err)
+	v, err = DecodeBinaryPlist(data)
+	require.NoError(t, err)
+	var out marshalTile
+	require.NoError(t, Unmarshal(v, &out))
+	require.Equal(t, in, out)
+}
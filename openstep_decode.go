@@ -0,0 +1,415 @@
+package cf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// OpenStep ("old-style" ASCII) property lists, as printed by `defaults read`
+// and used by .strings files:
+//
+//   { key = value; "quoted key" = ( a, "b c", <0001feff> ); }
+//
+// The format has no numbers, booleans or dates: like CFPropertyListCreateWithData,
+// DecodeOpenStepPlist returns those as strings. The GNUstep typed extensions
+// <*I42>, <*R1.5>, <*BY>/<*BN> and <*D2019-03-04 05:06:07 +0000> are decoded
+// into int64, float64, bool and time.Time.
+
+const openStepDateFormat = "2006-01-02 15:04:05 -0700"
+
+// InvalidOpenStepPlistError is returned when OpenStep property list data is malformed.
+type InvalidOpenStepPlistError struct {
+	Line   int
+	Reason string
+}
+
+func (e *InvalidOpenStepPlistError) Error() string {
+	return fmt.Sprintf("plist: invalid OpenStep plist at line %d: %s", e.Line, e.Reason)
+}
+
+type openStepDecoder struct {
+	data []byte
+	pos  int
+}
+
+// DecodeOpenStepPlist decodes an OpenStep property list into Go values:
+// string, []byte, []interface{} (nil when empty), map[string]interface{} and,
+// for GNUstep typed values, int64, float64, bool and time.Time.
+//
+// A top level sequence of `key = value;` pairs without enclosing braces, as in
+// .strings files, is decoded as a dictionary.
+func DecodeOpenStepPlist(data []byte) (interface{}, error) {
+	v, err := DecodeOpenStepPlistValue(data)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// DecodeOpenStepPlistValue decodes an OpenStep property list into a Value,
+// keeping dictionary key order.
+func DecodeOpenStepPlistValue(data []byte) (Value, error) {
+	d := &openStepDecoder{data: data}
+	// skip a UTF-8 byte order mark
+	d.pos = len(data) - len(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+
+	if err := d.skipSpace(); err != nil {
+		return nil, err
+	}
+	if d.pos == len(d.data) {
+		// an empty .strings file
+		return Dict{}, nil
+	}
+
+	var v Value
+	var err error
+	if d.isStringsFile() {
+		v, err = d.dictBody(0)
+	} else {
+		v, err = d.value()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := d.skipSpace(); err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, d.fail("unexpected %q after the top level value", d.data[d.pos])
+	}
+	return v, nil
+}
+
+func (d *openStepDecoder) fail(format string, args ...interface{}) error {
+	line := 1 + bytes.Count(d.data[:d.pos], []byte("\n"))
+	return &InvalidOpenStepPlistError{Line: line, Reason: fmt.Sprintf(format, args...)}
+}
+
+// isStringsFile reports whether the top level is a string followed by '=' or ';'
+func (d *openStepDecoder) isStringsFile() bool {
+	c := d.data[d.pos]
+	if c != '"' && c != '\'' && !isOpenStepUnquoted(c) {
+		return false
+	}
+	probe := &openStepDecoder{data: d.data, pos: d.pos}
+	if _, err := probe.string(); err != nil {
+		return false
+	}
+	if err := probe.skipSpace(); err != nil || probe.pos == len(probe.data) {
+		return false
+	}
+	return probe.data[probe.pos] == '=' || probe.data[probe.pos] == ';'
+}
+
+// skipSpace skips whitespace and comments
+func (d *openStepDecoder) skipSpace() error {
+	for d.pos < len(d.data) {
+		switch c := d.data[d.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			d.pos++
+		case bytes.HasPrefix(d.data[d.pos:], []byte("//")):
+			end := bytes.IndexByte(d.data[d.pos:], '\n')
+			if end < 0 {
+				d.pos = len(d.data)
+			} else {
+				d.pos += end + 1
+			}
+		case bytes.HasPrefix(d.data[d.pos:], []byte("/*")):
+			end := bytes.Index(d.data[d.pos+2:], []byte("*/"))
+			if end < 0 {
+				return d.fail("unterminated comment")
+			}
+			d.pos += 2 + end + 2
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// peek skips whitespace and returns the next byte without consuming it
+func (d *openStepDecoder) peek() (byte, error) {
+	if err := d.skipSpace(); err != nil {
+		return 0, err
+	}
+	if d.pos == len(d.data) {
+		return 0, d.fail("unexpected end of data")
+	}
+	return d.data[d.pos], nil
+}
+
+func (d *openStepDecoder) value() (Value, error) {
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c == '{':
+		d.pos++
+		return d.dictBody('}')
+	case c == '(':
+		d.pos++
+		return d.array()
+	case c == '<':
+		d.pos++
+		if d.pos < len(d.data) && d.data[d.pos] == '*' {
+			return d.typed()
+		}
+		return d.hexData()
+	case c == '"' || c == '\'' || isOpenStepUnquoted(c):
+		s, err := d.string()
+		return String(s), err
+	}
+	return nil, d.fail("unexpected %q", c)
+}
+
+// dictBody parses dictionary entries up to the closing byte (0 for end of data)
+func (d *openStepDecoder) dictBody(closing byte) (Dict, error) {
+	out := Dict{}
+	for {
+		if closing == 0 {
+			if err := d.skipSpace(); err != nil {
+				return nil, err
+			}
+			if d.pos == len(d.data) {
+				return out, nil
+			}
+		}
+		c, err := d.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == closing {
+			d.pos++
+			return out, nil
+		}
+		key, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		c, err = d.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == ';' && closing == 0 {
+			// .strings shorthand: "key"; means "key" = "key";
+			d.pos++
+			out.Set(key, String(key))
+			continue
+		}
+		if c != '=' {
+			return nil, d.fail("expected '=' after key %q, found %q", key, c)
+		}
+		d.pos++
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		out.Set(key, v)
+		c, err = d.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c != ';' {
+			return nil, d.fail("expected ';' after the value of %q, found %q", key, c)
+		}
+		d.pos++
+	}
+}
+
+func (d *openStepDecoder) array() (Array, error) {
+	out := Array{}
+	for {
+		c, err := d.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == ')' {
+			d.pos++
+			return out, nil
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+		c, err = d.peek()
+		if err != nil {
+			return nil, err
+		}
+		switch c {
+		case ',':
+			// a trailing comma is allowed
+			d.pos++
+		case ')':
+		default:
+			return nil, d.fail("expected ',' or ')' in array, found %q", c)
+		}
+	}
+}
+
+func (d *openStepDecoder) hexData() (Data, error) {
+	end := bytes.IndexByte(d.data[d.pos:], '>')
+	if end < 0 {
+		return nil, d.fail("unterminated data")
+	}
+	digits := strings.Map(dropSpace, string(d.data[d.pos:d.pos+end]))
+	data, err := hex.DecodeString(digits)
+	if err != nil {
+		return nil, d.fail("invalid hex data")
+	}
+	d.pos += end + 1
+	return data, nil
+}
+
+// typed parses the GNUstep <*Tvalue> extensions, after the opening '<'
+func (d *openStepDecoder) typed() (Value, error) {
+	end := bytes.IndexByte(d.data[d.pos:], '>')
+	if end < 2 {
+		return nil, d.fail("unterminated typed value")
+	}
+	typ, s := d.data[d.pos+1], string(d.data[d.pos+2:d.pos+end])
+	var v Value
+	var err error
+	switch typ {
+	case 'I':
+		var i int64
+		i, err = strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		v = Int(i)
+	case 'R':
+		var f float64
+		f, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+		v = Real{f, 8}
+	case 'B':
+		switch s {
+		case "Y":
+			v = Bool(true)
+		case "N":
+			v = Bool(false)
+		default:
+			err = fmt.Errorf("invalid boolean")
+		}
+	case 'D':
+		var t time.Time
+		t, err = time.Parse(openStepDateFormat, strings.TrimSpace(s))
+		v = Date{t}
+	default:
+		return nil, d.fail("unknown typed value <*%c>", typ)
+	}
+	if err != nil {
+		return nil, d.fail("invalid typed value <*%c%s>", typ, s)
+	}
+	d.pos += end + 1
+	return v, nil
+}
+
+func isOpenStepUnquoted(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '_' || c == '$' || c == '+' || c == '/' || c == ':' || c == '.' || c == '-'
+}
+
+func (d *openStepDecoder) string() (string, error) {
+	c, err := d.peek()
+	if err != nil {
+		return "", err
+	}
+	if c != '"' && c != '\'' {
+		start := d.pos
+		for d.pos < len(d.data) && isOpenStepUnquoted(d.data[d.pos]) {
+			d.pos++
+		}
+		if start == d.pos {
+			return "", d.fail("expected a string, found %q", c)
+		}
+		return string(d.data[start:d.pos]), nil
+	}
+
+	quote := c
+	d.pos++
+	var units []uint16
+	var sb strings.Builder
+	flush := func() {
+		if len(units) != 0 {
+			sb.WriteString(string(utf16.Decode(units)))
+			units = units[:0]
+		}
+	}
+	for {
+		if d.pos == len(d.data) {
+			return "", d.fail("unterminated string")
+		}
+		c := d.data[d.pos]
+		d.pos++
+		if c == quote {
+			flush()
+			return sb.String(), nil
+		}
+		if c != '\\' {
+			flush()
+			sb.WriteByte(c)
+			continue
+		}
+		if d.pos == len(d.data) {
+			return "", d.fail("unterminated string")
+		}
+		c = d.data[d.pos]
+		d.pos++
+		switch c {
+		case 'U':
+			// up to four hex digits; surrogate pairs are collected in units
+			n := 0
+			var u uint16
+			for n < 4 && d.pos < len(d.data) && isHexDigit(d.data[d.pos]) {
+				v, _ := strconv.ParseUint(string(d.data[d.pos]), 16, 8)
+				u = u<<4 | uint16(v)
+				d.pos++
+				n++
+			}
+			if n == 0 {
+				return "", d.fail("invalid \\U escape")
+			}
+			units = append(units, u)
+			continue
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// up to three octal digits, a byte in the NeXTSTEP encoding; only the
+			// ASCII range maps directly, the rest is read as Latin-1
+			v := uint(c - '0')
+			for n := 1; n < 3 && d.pos < len(d.data) && '0' <= d.data[d.pos] && d.data[d.pos] <= '7'; n++ {
+				v = v<<3 | uint(d.data[d.pos]-'0')
+				d.pos++
+			}
+			flush()
+			sb.WriteRune(rune(v & 0xff))
+			continue
+		}
+		flush()
+		switch c {
+		case 'a':
+			sb.WriteByte('\a')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'v':
+			sb.WriteByte('\v')
+		default:
+			// \" \' \\ and any other escaped character stand for themselves
+			sb.WriteByte(c)
+		}
+	}
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
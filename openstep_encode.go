@@ -0,0 +1,131 @@
+package cf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// EncodeOpenStepPlist encodes v in the format `defaults read` prints: four
+// space indentation, sorted dictionary keys (or Dict order), quoted strings with \U escapes for
+// non-ASCII characters, and numbers, booleans (as 1/0) and dates as plain text.
+//
+// The format has no types for numbers, booleans and dates, so they come back
+// as strings from DecodeOpenStepPlist. v may be any value Pool.Object accepts,
+// and may contain Values.
+func EncodeOpenStepPlist(v interface{}) ([]byte, error) {
+	val, err := objectValue(v)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	writeOpenStepValue(buf, val, 0)
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// writeOpenStepValue writes val as NSArray/NSDictionary -descriptionWithLocale:indent:
+// would: containers start with their own indentation, even after "key = "
+func writeOpenStepValue(buf *bytes.Buffer, val Value, level int) {
+	indent := strings.Repeat("    ", level)
+	switch v := val.(type) {
+	case Bool:
+		if v {
+			buf.WriteString("1")
+		} else {
+			buf.WriteString("0")
+		}
+	case Integer:
+		buf.WriteString(formatInteger(v))
+	case Real:
+		if v.Width == 4 {
+			buf.WriteString(strconv.FormatFloat(v.Value, 'g', -1, 32))
+		} else {
+			buf.WriteString(strconv.FormatFloat(v.Value, 'g', -1, 64))
+		}
+	case Date:
+		writeOpenStepString(buf, v.UTC().Format(openStepDateFormat))
+	case Data:
+		buf.WriteString("<")
+		for i := 0; i < len(v); i += 4 {
+			if i > 0 {
+				buf.WriteString(" ")
+			}
+			end := i + 4
+			if end > len(v) {
+				end = len(v)
+			}
+			buf.WriteString(hex.EncodeToString(v[i:end]))
+		}
+		buf.WriteString(">")
+	case String:
+		writeOpenStepString(buf, string(v))
+	case UID:
+		writeOpenStepValue(buf, Dict{{xmlPlistUIDKey, Uint(uint64(v))}}, level)
+	case Array:
+		buf.WriteString(indent + "(\n")
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			buf.WriteString(indent + "    ")
+			writeOpenStepValue(buf, elem, level+1)
+		}
+		if len(v) > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + ")")
+	case Dict:
+		buf.WriteString(indent + "{\n")
+		for _, e := range v {
+			buf.WriteString(indent + "    ")
+			writeOpenStepString(buf, e.Key)
+			buf.WriteString(" = ")
+			writeOpenStepValue(buf, e.Value, level+1)
+			buf.WriteString(";\n")
+		}
+		buf.WriteString(indent + "}")
+	default:
+		panic("plist: unexpected Value type")
+	}
+}
+
+// writeOpenStepString writes s unquoted if it only consists of ASCII letters
+// and digits, and quoted and escaped otherwise
+func writeOpenStepString(buf *bytes.Buffer, s string) {
+	plain := s != ""
+	for i := 0; i < len(s) && plain; i++ {
+		c := s[i]
+		plain = 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+	}
+	if plain {
+		buf.WriteString(s)
+		return
+	}
+
+	buf.WriteString(`"`)
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteString(`\` + string(r))
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(buf, `\%03o`, r)
+		case r < 0x80:
+			buf.WriteRune(r)
+		default:
+			for _, u := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(buf, `\U%04x`, u)
+			}
+		}
+	}
+	buf.WriteString(`"`)
+}
@@ -0,0 +1,101 @@
+package cf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const openStepFixture = `{
+    AppleLanguages =     (
+        "en-US",
+        ru
+    );
+    NSUserKeyEquivalents =     {
+        Print = "@$p";
+    };
+    "com.apple.swipescrolldirection" = 0;
+    data = <00010203 04>;
+    empty =     (
+    );
+    name = "h\U00e9llo \U2603 \"quoted\"\n";
+    "persistent-apps" =     (
+                {
+            "tile-data" =             {
+                "file-label" = Safari;
+            };
+        }
+    );
+    size = "1.5";
+}
+`
+
+func TestEncodeOpenStepPlist(t *testing.T) {
+	out, err := EncodeOpenStepPlist(map[string]interface{}{
+		"AppleLanguages":                 []string{"en-US", "ru"},
+		"NSUserKeyEquivalents":           map[string]string{"Print": "@$p"},
+		"com.apple.swipescrolldirection": false,
+		"data":                           []byte{0, 1, 2, 3, 4},
+		"empty":                          []string{},
+		"name":                           "héllo ☃ \"quoted\"\n",
+		"persistent-apps": []interface{}{
+			map[string]interface{}{"tile-data": map[string]interface{}{"file-label": "Safari"}},
+		},
+		"size": "1.5",
+	})
+	require.NoError(t, err)
+	require.Equal(t, openStepFixture, string(out))
+}
+
+func TestDecodeOpenStepPlist(t *testing.T) {
+	v, err := DecodeOpenStepPlist([]byte(openStepFixture))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"AppleLanguages":                 []interface{}{"en-US", "ru"},
+		"NSUserKeyEquivalents":           map[string]interface{}{"Print": "@$p"},
+		"com.apple.swipescrolldirection": "0",
+		"data":                           []byte{0, 1, 2, 3, 4},
+		"empty":                          []interface{}(nil),
+		"name":                           "héllo ☃ \"quoted\"\n",
+		"persistent-apps": []interface{}{
+			map[string]interface{}{"tile-data": map[string]interface{}{"file-label": "Safari"}},
+		},
+		"size": "1.5",
+	}, v)
+}
+
+func TestDecodeOpenStepPlistStrings(t *testing.T) {
+	v, err := DecodeOpenStepPlist([]byte("\xef\xbb\xbf/* menu */\n\"Open\" = \"\\U00d6ffnen\";\n" +
+		"// no translation\n\"OK\";\nemoji = \"\\Ud83d\\Ude00\\101\";\n"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"Open": "Öffnen", "OK": "OK", "emoji": "😀A"}, v)
+
+	v, err = DecodeOpenStepPlist([]byte(""))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{}, v)
+}
+
+func TestDecodeOpenStepPlistTyped(t *testing.T) {
+	v, err := DecodeOpenStepPlist([]byte(`(<*I-42>, <*R1.5>, <*BY>, <*BN>, <*D2019-03-04 05:06:07 +0000>, a,)`))
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{int64(-42), 1.5, true, false, time.Unix(1551675967, 0), "a"}, v)
+}
+
+func TestDecodeOpenStepPlistInvalid(t *testing.T) {
+	for _, doc := range []string{
+		`{ a = b }`,
+		`{ a b; }`,
+		`( a b )`,
+		`"unterminated`,
+		`<0g>`,
+		`<*Ix>`,
+		`<*Q1>`,
+		`/* open`,
+		`( a ) b`,
+		`{ = b; }`,
+	} {
+		_, err := DecodeOpenStepPlist([]byte(doc))
+		require.IsType(t, &InvalidOpenStepPlistError{}, err, doc)
+	}
+}
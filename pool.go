@@ -1,3 +1,6 @@
+//go:build darwin
+// +build darwin
+
 package cf
 
 // Taken from go-osx-plist (see LICENSE), heavily adapted
@@ -17,6 +20,17 @@ package cf
 //         keyCallBacks, valueCallBacks);
 // }
 //
+// typedef const struct __CFKeyedArchiverUID *CFKeyedArchiverUIDRef;
+// CFKeyedArchiverUIDRef _CFKeyedArchiverUIDCreate(CFAllocatorRef allocator, uint32_t value);
+//
+// // kCFNumberSInt128Type is private, but CFNumber stores integers outside of
+// // the SInt64 range this way, and CFNumberCreate/CFNumberGetValue accept it
+// #define gocf_kCFNumberSInt128Type 17
+// typedef struct {
+//     int64_t high;
+//     uint64_t low;
+// } gocf_CFSInt128Struct;
+//
 import "C"
 import (
 	"fmt"
@@ -114,6 +128,22 @@ func (p *Pool) Uint32(u uint32) numberRef {
 	return p.Int64(int64(u))
 }
 
+// Uint64 creates a CFNumber of type kCFNumberSInt64Type, or kCFNumberSInt128Type
+// if u does not fit in an int64.
+func (p *Pool) Uint64(u uint64) numberRef {
+	if u <= math.MaxInt64 {
+		return p.Int64(int64(u))
+	}
+	return p.sint128(0, u)
+}
+
+func (p *Pool) sint128(high int64, low uint64) numberRef {
+	sint128 := C.gocf_CFSInt128Struct{high: C.int64_t(high), low: C.uint64_t(low)}
+	v := C.CFNumberCreate(0, C.gocf_kCFNumberSInt128Type, unsafe.Pointer(&sint128))
+	p.autorelease(typeRef(v))
+	return numberRef(v)
+}
+
 func (p *Pool) Data(data []byte) dataRef {
 	if len(data) == 0 {
 		return dataRef(C.CFDataCreate(0, nil, 0))
@@ -130,6 +160,103 @@ func (p *Pool) Date(t time.Time) dateRef {
 	return dateRef(C.CFDateCreate(0, C.CFAbsoluteTime(nano)))
 }
 
+// Integer creates a CFNumber of the signed type matching the width of i, 8
+// bytes if it is not set. Unsigned integers use the next wider type, as
+// CFNumber has no unsigned types, and kCFNumberSInt128Type holds whatever does
+// not fit in 64 bits. It returns an *OverflowError if the value does not fit in
+// the width.
+func (p *Pool) Integer(i Integer) (numberRef, error) {
+	width, err := i.cfWidth()
+	if err != nil {
+		return 0, err
+	}
+	var v C.CFNumberRef
+	switch {
+	case width <= 1:
+		sint8 := C.SInt8(i.Value)
+		v = C.CFNumberCreate(0, C.kCFNumberSInt8Type, unsafe.Pointer(&sint8))
+	case width <= 2:
+		sint16 := C.SInt16(i.Value)
+		v = C.CFNumberCreate(0, C.kCFNumberSInt16Type, unsafe.Pointer(&sint16))
+	case width <= 4:
+		sint32 := C.SInt32(i.Value)
+		v = C.CFNumberCreate(0, C.kCFNumberSInt32Type, unsafe.Pointer(&sint32))
+	case i.Signed:
+		if width > 8 && int64(i.Value) < 0 {
+			return p.sint128(-1, i.Value), nil
+		}
+		return p.Int64(int64(i.Value)), nil
+	default:
+		return p.Uint64(i.Value), nil
+	}
+	p.autorelease(typeRef(v))
+	return numberRef(v), nil
+}
+
+// Value creates the CF object for v, keeping number types, full date precision
+// and UIDs. Dictionary key order is not kept, as CFDictionary has none.
+func (p *Pool) Value(v Value) (typeRef, error) {
+	switch v := v.(type) {
+	case String:
+		s, err := p.String(string(v))
+		return typeRef(s), err
+	case Integer:
+		n, err := p.Integer(v)
+		return typeRef(n), err
+	case Real:
+		if v.Width == 4 {
+			return typeRef(p.Float32(float32(v.Value))), nil
+		}
+		return typeRef(p.Float64(v.Value)), nil
+	case Bool:
+		return typeRef(p.Bool(bool(v))), nil
+	case Date:
+		d := C.CFDateCreate(0, C.CFAbsoluteTime(v.absoluteTime()))
+		p.autorelease(typeRef(d))
+		return typeRef(d), nil
+	case Data:
+		return typeRef(p.Data(v)), nil
+	case UID:
+		if v > math.MaxUint32 {
+			return 0, &UnsupportedValueError{reflect.ValueOf(v), "UID out of range"}
+		}
+		uid := C._CFKeyedArchiverUIDCreate(0, C.uint32_t(v))
+		p.autorelease(typeRef(uid))
+		return typeRef(uid), nil
+	case Array:
+		if len(v) == 0 {
+			return typeRef(C.CFArrayCreate(0, nil, 0, nil)), nil
+		}
+		cvalues := make([]C.uintptr_t, len(v))
+		for i, elem := range v {
+			obj, err := p.Value(elem)
+			if err != nil {
+				return 0, errors.Wrap(err, "failed to create CFArray")
+			}
+			cvalues[i] = C.uintptr_t(obj)
+		}
+		callbacks := (*C.CFArrayCallBacks)(&C.kCFTypeArrayCallBacks)
+		return typeRef(C.gocf_CFArrayCreate(0, &cvalues[0], C.CFIndex(len(cvalues)), callbacks)), nil
+	case Dict:
+		ckeys := make([]C.uintptr_t, len(v))
+		cvalues := make([]C.uintptr_t, len(v))
+		for i, e := range v {
+			cfkey, err := p.String(e.Key)
+			if err != nil {
+				return 0, err
+			}
+			ckeys[i] = C.uintptr_t(cfkey)
+			cfval, err := p.Value(e.Value)
+			if err != nil {
+				return 0, err
+			}
+			cvalues[i] = C.uintptr_t(cfval)
+		}
+		return typeRef(createDictionary(ckeys, cvalues)), nil
+	}
+	return 0, &UnsupportedTypeError{reflect.TypeOf(v)}
+}
+
 func (p *Pool) Object(i interface{}) (typeRef, error) {
 	return p.refObject(reflect.ValueOf(i))
 }
@@ -179,6 +306,31 @@ func (p *Pool) Dictionary(m interface{}) (dictionaryRef, error) {
 		cvalues = append(cvalues, C.uintptr_t(cfval))
 	}
 
+	return createDictionary(ckeys, cvalues), nil
+}
+
+// structDictionary creates a CFDictionary from the fields of a struct, see Marshal
+func (p *Pool) structDictionary(v reflect.Value) (dictionaryRef, error) {
+	keys, values := structEntries(v)
+	ckeys := make([]C.uintptr_t, len(keys))
+	cvalues := make([]C.uintptr_t, len(keys))
+	for i, key := range keys {
+		cfkey, err := p.String(key)
+		if err != nil {
+			return 0, err
+		}
+		ckeys[i] = C.uintptr_t(cfkey)
+
+		cfval, err := p.refObject(values[i])
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to convert field %s", key)
+		}
+		cvalues[i] = C.uintptr_t(cfval)
+	}
+	return createDictionary(ckeys, cvalues), nil
+}
+
+func createDictionary(ckeys, cvalues []C.uintptr_t) dictionaryRef {
 	var keyPtr, valuePtr *C.uintptr_t
 	if len(ckeys) > 0 {
 		keyPtr = &ckeys[0]
@@ -188,13 +340,16 @@ func (p *Pool) Dictionary(m interface{}) (dictionaryRef, error) {
 	keyCallbacks := (*C.CFDictionaryKeyCallBacks)(&C.kCFTypeDictionaryKeyCallBacks)
 	valueCallbacks := (*C.CFDictionaryValueCallBacks)(&C.kCFTypeDictionaryValueCallBacks)
 	return dictionaryRef(C.gocf_CFDictionaryCreate(0, keyPtr, valuePtr, C.CFIndex(len(ckeys)),
-		keyCallbacks, valueCallbacks)), nil
+		keyCallbacks, valueCallbacks))
 }
 
 func (p *Pool) refObject(v reflect.Value) (typeRef, error) {
 	if !v.IsValid() {
 		return 0, nil
 	}
+	if v.Type().Implements(valueType) && (v.Kind() != reflect.Interface && v.Kind() != reflect.Ptr || !v.IsNil()) {
+		return p.Value(v.Interface().(Value))
+	}
 	switch v.Kind() {
 	case reflect.Bool:
 		return typeRef(p.Bool(v.Bool())), nil
@@ -202,11 +357,8 @@ func (p *Pool) refObject(v reflect.Value) (typeRef, error) {
 		return typeRef(p.Int64(v.Int())), nil
 	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
 		return typeRef(p.Uint32(uint32(v.Uint()))), nil
-	case reflect.Uint, reflect.Uintptr:
-		// don't try and convert if uint/uintptr is 64-bits
-		if v.Type().Bits() < 64 {
-			return typeRef(p.Uint32(uint32(v.Uint()))), nil
-		}
+	case reflect.Uint, reflect.Uintptr, reflect.Uint64:
+		return typeRef(p.Uint64(v.Uint())), nil
 	case reflect.Float32:
 		return typeRef(p.Float32(float32(v.Float()))), nil
 	case reflect.Float64:
@@ -215,10 +367,11 @@ func (p *Pool) refObject(v reflect.Value) (typeRef, error) {
 		s, err := p.String(v.String())
 		return typeRef(s), err
 	case reflect.Struct:
-		// only struct type we support is time.Time
-		if v.Type() == reflect.TypeOf(time.Time{}) {
+		if v.Type() == timeType {
 			return typeRef(p.Date(v.Interface().(time.Time))), nil
 		}
+		dict, err := p.structDictionary(v)
+		return typeRef(dict), err
 	case reflect.Array, reflect.Slice:
 		// check for []byte first (byte is uint8)
 		if v.Type().Elem().Kind() == reflect.Uint8 {
@@ -234,6 +387,11 @@ func (p *Pool) refObject(v reflect.Value) (typeRef, error) {
 			return 0, &UnsupportedValueError{v, "nil interface"}
 		}
 		return p.refObject(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return 0, &UnsupportedValueError{v, "nil pointer"}
+		}
+		return p.refObject(v.Elem())
 	}
 	return 0, &UnsupportedTypeError{v.Type()}
 }
@@ -0,0 +1,342 @@
+//go:build darwin
+// +build darwin
+
+package cf
+
+// #import <CoreFoundation/CoreFoundation.h>
+import "C"
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+func Preferences(key, appID, userName, hostName string) (interface{}, error) {
+	pool := Pool{}
+	defer pool.Release()
+
+	var key_, appID_, userName_, hostName_ stringRef
+	var err error
+
+	if key_, err = pool.String(key); err != nil {
+		return nil, errors.Wrapf(err, "failed Preferences(%s)", key)
+	}
+	if appID_, err = pool.String(appID); err != nil {
+		return nil, errors.Wrapf(err, "failed Preferences(%s)", key)
+	}
+	if userName_, err = pool.String(userName); err != nil {
+		return nil, errors.Wrapf(err, "failed Preferences(%s)", key)
+	}
+	if hostName_, err = pool.String(hostName); err != nil {
+		return nil, errors.Wrapf(err, "failed Preferences(%s)", key)
+	}
+
+	prefs := typeRef(C.CFPreferencesCopyValue(C.CFStringRef(key_), C.CFStringRef(appID_),
+		C.CFStringRef(userName_), C.CFStringRef(hostName_)))
+	defer Release(prefs)
+	return prefs.Goize()
+}
+
+func PreferencesSet(key string, value interface{}, appID string, userName string, hostName string) error {
+	pool := &Pool{}
+	defer pool.Release()
+
+	var key_, appID_, userName_, hostName_ stringRef
+	var val_ typeRef
+	var err error
+
+	if key_, err = pool.String(key); err != nil {
+		return errors.Wrapf(err, "failed PreferencesSet(%s)", key)
+	}
+	if appID_, err = pool.String(appID); err != nil {
+		return errors.Wrapf(err, "failed PreferencesSet(%s)", key)
+	}
+	if userName_, err = pool.String(userName); err != nil {
+		return errors.Wrapf(err, "failed PreferencesSet(%s)", key)
+	}
+	if hostName_, err = pool.String(hostName); err != nil {
+		return errors.Wrapf(err, "failed PreferencesSet(%s)", key)
+	}
+
+	if val_, err = pool.Object(value); err != nil {
+		return errors.Wrapf(err, "failed PreferencesSet(%s)", key)
+	}
+	C.CFPreferencesSetValue(C.CFStringRef(key_), C.CFTypeRef(val_), C.CFStringRef(appID_),
+		C.CFStringRef(userName_), C.CFStringRef(hostName_))
+	return nil
+}
+
+func PreferencesSetMulti(keys map[string]interface{}, appID string, userName string, hostName string) error {
+	pool := &Pool{}
+	defer pool.Release()
+
+	cfAppID, err := pool.String(appID)
+	if err != nil {
+		return errors.Wrapf(err, "failed PreferencesSetMulti")
+	}
+	cfUserName, err := pool.String(userName)
+	if err != nil {
+		return errors.Wrap(err, "failed PreferercesSetMulti")
+	}
+	cfHostName, err := pool.String(hostName)
+	if err != nil {
+		return errors.Wrap(err, "failed PreferencesSetMulti")
+	}
+
+	delKeys := []string{}
+	setKeys := map[string]interface{}{}
+	for k, v := range keys {
+		if v == nil {
+			delKeys = append(delKeys, k)
+		} else {
+			setKeys[k] = v
+		}
+	}
+	cfDelKeys, err := pool.Array(delKeys)
+	if err != nil {
+		return errors.Wrap(err, "failed PreferencesSetMulti")
+	}
+	cfSetKeys, err := pool.Dictionary(setKeys)
+	if err != nil {
+		return fmt.Errorf("Unable to convert %q to CFDictionary", setKeys)
+	}
+	C.CFPreferencesSetMultiple(C.CFDictionaryRef(cfSetKeys), C.CFArrayRef(cfDelKeys),
+		C.CFStringRef(cfAppID), C.CFStringRef(cfUserName), C.CFStringRef(cfHostName))
+	return nil
+}
+
+func PreferencesSynchronize(appID, userName, hostName string) (bool, error) {
+	pool := &Pool{}
+	defer pool.Release()
+
+	var appID_, userName_, hostName_ stringRef
+	var err error
+
+	if appID_, err = pool.String(appID); err != nil {
+		return false, errors.Wrap(err, "failed PreferencesSynchronize")
+	}
+	if userName_, err = pool.String(userName); err != nil {
+		return false, errors.Wrap(err, "failed PreferencesSynchronize")
+	}
+	if hostName_, err = pool.String(hostName); err != nil {
+		return false, errors.Wrap(err, "failed PreferencesSynchronize")
+	}
+
+	return C.CFPreferencesSynchronize(C.CFStringRef(appID_), C.CFStringRef(userName_),
+		C.CFStringRef(hostName_)) != 0, nil
+}
+
+func PreferencesKeyList(appID, userName, hostName string) ([]string, error) {
+	pool := &Pool{}
+	defer pool.Release()
+
+	var appID_, userName_, hostName_ stringRef
+	var err error
+
+	if appID_, err = pool.String(appID); err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesKeyList")
+	}
+	if userName_, err = pool.String(userName); err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesKeyList")
+	}
+	if hostName_, err = pool.String(hostName); err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesKeyList")
+	}
+
+	keys := C.CFPreferencesCopyKeyList(C.CFStringRef(appID_), C.CFStringRef(userName_), C.CFStringRef(hostName_))
+	if keys == 0 {
+		return nil, nil
+	}
+	defer Release(typeRef(keys))
+	v, err := arrayRef(keys).Goize()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesKeyList")
+	}
+	out := make([]string, len(v))
+	for i, key := range v {
+		out[i] = key.(string)
+	}
+	return out, nil
+}
+
+// PreferencesMulti returns the values of keys in the domain, or of all keys if
+// keys is nil. Keys that are not set are missing from the result.
+func PreferencesMulti(keys []string, appID, userName, hostName string) (map[string]interface{}, error) {
+	pool := &Pool{}
+	defer pool.Release()
+
+	var appID_, userName_, hostName_ stringRef
+	var keys_ arrayRef
+	var err error
+
+	if appID_, err = pool.String(appID); err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesMulti")
+	}
+	if userName_, err = pool.String(userName); err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesMulti")
+	}
+	if hostName_, err = pool.String(hostName); err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesMulti")
+	}
+	if keys != nil {
+		if keys_, err = pool.Array(keys); err != nil {
+			return nil, errors.Wrap(err, "failed PreferencesMulti")
+		}
+		defer Release(typeRef(keys_))
+	}
+
+	values := C.CFPreferencesCopyMultiple(C.CFArrayRef(keys_), C.CFStringRef(appID_),
+		C.CFStringRef(userName_), C.CFStringRef(hostName_))
+	defer Release(typeRef(values))
+	if values == 0 {
+		return map[string]interface{}{}, nil
+	}
+	v, err := dictionaryRef(values).Goize()
+	return v, errors.Wrap(err, "failed PreferencesMulti")
+}
+
+// PreferencesValues returns all keys of the domain as Values, which keep the
+// width of numbers and the precision of dates that Goize drops.
+func PreferencesValues(appID, userName, hostName string) (Dict, error) {
+	pool := &Pool{}
+	defer pool.Release()
+
+	var appID_, userName_, hostName_ stringRef
+	var err error
+
+	if appID_, err = pool.String(appID); err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesValues")
+	}
+	if userName_, err = pool.String(userName); err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesValues")
+	}
+	if hostName_, err = pool.String(hostName); err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesValues")
+	}
+
+	values := C.CFPreferencesCopyMultiple(0, C.CFStringRef(appID_),
+		C.CFStringRef(userName_), C.CFStringRef(hostName_))
+	defer Release(typeRef(values))
+	if values == 0 {
+		return Dict{}, nil
+	}
+	v, err := dictionaryRef(values).Value()
+	return v, errors.Wrap(err, "failed PreferencesValues")
+}
+
+// PreferencesApplicationList returns the application IDs that have preferences
+// for the user and host. The global domain is listed as
+// PreferencesAnyApplication.
+func PreferencesApplicationList(userName, hostName string) ([]string, error) {
+	pool := &Pool{}
+	defer pool.Release()
+
+	var userName_, hostName_ stringRef
+	var err error
+
+	if userName_, err = pool.String(userName); err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesApplicationList")
+	}
+	if hostName_, err = pool.String(hostName); err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesApplicationList")
+	}
+
+	apps := C.CFPreferencesCopyApplicationList(C.CFStringRef(userName_), C.CFStringRef(hostName_))
+	if apps == 0 {
+		return nil, nil
+	}
+	defer Release(typeRef(apps))
+	v, err := arrayRef(apps).Goize()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesApplicationList")
+	}
+	out := make([]string, len(v))
+	for i, app := range v {
+		out[i] = app.(string)
+		if out[i] == globalPreferencesName {
+			out[i] = PreferencesAnyApplication
+		}
+	}
+	return out, nil
+}
+
+// PreferencesApp returns the value of key the application sees: the first one
+// found in the search list of appID, starting with managed preferences.
+func PreferencesApp(key, appID string) (interface{}, error) {
+	pool := &Pool{}
+	defer pool.Release()
+
+	var key_, appID_ stringRef
+	var err error
+
+	if key_, err = pool.String(key); err != nil {
+		return nil, errors.Wrapf(err, "failed PreferencesApp(%s)", key)
+	}
+	if appID_, err = pool.String(appID); err != nil {
+		return nil, errors.Wrapf(err, "failed PreferencesApp(%s)", key)
+	}
+
+	prefs := typeRef(C.CFPreferencesCopyAppValue(C.CFStringRef(key_), C.CFStringRef(appID_)))
+	defer Release(prefs)
+	return prefs.Goize()
+}
+
+func PreferencesAppSynchronize(appID string) (bool, error) {
+	pool := &Pool{}
+	defer pool.Release()
+
+	appID_, err := pool.String(appID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed PreferencesAppSynchronize")
+	}
+	return C.CFPreferencesAppSynchronize(C.CFStringRef(appID_)) != 0, nil
+}
+
+// PreferencesAppValueIsForced reports whether the value of key is managed,
+// e.g. by a configuration profile, so that the application cannot change it.
+func PreferencesAppValueIsForced(key, appID string) (bool, error) {
+	pool := &Pool{}
+	defer pool.Release()
+
+	var key_, appID_ stringRef
+	var err error
+
+	if key_, err = pool.String(key); err != nil {
+		return false, errors.Wrapf(err, "failed PreferencesAppValueIsForced(%s)", key)
+	}
+	if appID_, err = pool.String(appID); err != nil {
+		return false, errors.Wrapf(err, "failed PreferencesAppValueIsForced(%s)", key)
+	}
+	return C.CFPreferencesAppValueIsForced(C.CFStringRef(key_), C.CFStringRef(appID_)) != 0, nil
+}
+
+// PreferencesRemove removes key from the domain and reports whether it was
+// set.
+func PreferencesRemove(key, appID, userName, hostName string) (bool, error) {
+	v, err := Preferences(key, appID, userName, hostName)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed PreferencesRemove(%s)", key)
+	}
+	if v == nil {
+		return false, nil
+	}
+	return true, errors.Wrapf(PreferencesSet(key, nil, appID, userName, hostName), "failed PreferencesRemove(%s)", key)
+}
+
+// PreferencesRemoveDomain removes all keys from the domain and reports whether
+// there were any. The files of domains belong to cfprefsd, so removeFile is
+// ignored.
+func PreferencesRemoveDomain(appID, userName, hostName string, removeFile bool) (bool, error) {
+	keys, err := PreferencesKeyList(appID, userName, hostName)
+	if err != nil {
+		return false, errors.Wrap(err, "failed PreferencesRemoveDomain")
+	}
+	if len(keys) == 0 {
+		return false, nil
+	}
+	remove := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		remove[key] = nil
+	}
+	return true, errors.Wrap(PreferencesSetMulti(remove, appID, userName, hostName), "failed PreferencesRemoveDomain")
+}
@@ -0,0 +1,430 @@
+//go:build !darwin
+// +build !darwin
+
+package cf
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// PreferencesRoot is the directory the file-backed preferences treat as the
+// root of the file system. Preference domains live in
+//
+//	<root>/Library/Preferences                         (PreferencesAnyUser)
+//	<root>/<PreferencesHome>/Library/Preferences       (PreferencesCurrentUser)
+//	<root>/Users/<user name>/Library/Preferences       (any other user name)
+//
+// as <app>.plist, or ByHost/<app>.<host UUID>.plist for PreferencesCurrentHost,
+// with PreferencesAnyApplication stored as .GlobalPreferences. Managed
+// preferences are read from
+//
+//	<root>/Library/Managed Preferences/<user name>/<app>.plist
+//	<root>/Library/Managed Preferences/<app>.plist
+//
+// where the user name of the current user is the last element of
+// PreferencesHome.
+var PreferencesRoot = "/"
+
+// PreferencesHome is the home directory of the current user, $HOME by default.
+var PreferencesHome = os.Getenv("HOME")
+
+// PreferencesHostUUID is the hardware UUID in the names of ByHost files. If it
+// is empty, it is derived from the machine ID of the system.
+var PreferencesHostUUID = ""
+
+// preferencesLock serializes read-modify-write cycles of preference files
+var preferencesLock sync.Mutex
+
+// preferencesPath returns the file the domain is stored in
+func preferencesPath(appID, userName, hostName string) (string, error) {
+	name := appID
+	switch appID {
+	case "":
+		return "", fmt.Errorf("empty application ID")
+	case PreferencesAnyApplication:
+		name = globalPreferencesName
+	default:
+		if err := checkPreferencesName("application ID", appID); err != nil {
+			return "", err
+		}
+	}
+
+	var home string
+	switch userName {
+	case "":
+		return "", fmt.Errorf("empty user name")
+	case PreferencesAnyUser:
+		home = "/"
+	case PreferencesCurrentUser:
+		if PreferencesHome == "" {
+			return "", fmt.Errorf("home directory of the current user is unknown")
+		}
+		home = PreferencesHome
+	default:
+		if err := checkPreferencesName("user name", userName); err != nil {
+			return "", err
+		}
+		home = filepath.Join("/Users", userName)
+	}
+	dir := filepath.Join(PreferencesRoot, home, "Library", "Preferences")
+
+	switch hostName {
+	case PreferencesAnyHost:
+		return filepath.Join(dir, name+".plist"), nil
+	case PreferencesCurrentHost:
+		uuid, err := preferencesHostUUID()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, "ByHost", name+"."+uuid+".plist"), nil
+	}
+	return "", fmt.Errorf("unsupported host name %q", hostName)
+}
+
+// preferencesHostUUID formats the systemd/D-Bus machine ID the way macOS
+// formats hardware UUIDs
+func preferencesHostUUID() (string, error) {
+	if PreferencesHostUUID != "" {
+		return PreferencesHostUUID, nil
+	}
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		id := strings.ToUpper(string(bytes.TrimSpace(data)))
+		if len(id) == 32 {
+			return id[:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:], nil
+		}
+	}
+	return "", fmt.Errorf("host UUID is unknown, set PreferencesHostUUID")
+}
+
+// readPreferencesFile returns the dictionary stored in the file and its format.
+// A missing file is an empty binary one.
+func readPreferencesFile(path string) (Dict, Format, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return Dict{}, BinaryFormat, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	v, format, err := DecodeValue(f)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "failed to read %s", path)
+	}
+	dict, ok := v.(Dict)
+	if !ok {
+		return nil, 0, fmt.Errorf("%s does not contain a dictionary", path)
+	}
+	return dict, format, nil
+}
+
+// writePreferencesFile atomically replaces the file with dict in the given
+// format, or removes it if dict is empty
+func writePreferencesFile(path string, dict Dict, format Format, userName string) error {
+	if len(dict) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return replacePreferencesFile(path, dict, format, userName)
+}
+
+// replacePreferencesFile atomically replaces the file with dict in the given
+// format, keeping its permissions
+func replacePreferencesFile(path string, dict Dict, format Format, userName string) error {
+	data, err := Encode(dict, format)
+	if err != nil {
+		return err
+	}
+	perm := os.FileMode(0600)
+	if userName == PreferencesAnyUser {
+		perm = 0644
+	}
+	return writeFileAtomic(path, data, perm)
+}
+
+// updatePreferences sets the values of keys in the domain, removing the keys
+// with nil values. The file keeps its format, and new keys are added in order.
+func updatePreferences(keys []string, values map[string]interface{}, appID, userName, hostName string) error {
+	path, err := preferencesPath(appID, userName, hostName)
+	if err != nil {
+		return err
+	}
+
+	preferencesLock.Lock()
+	defer preferencesLock.Unlock()
+
+	dict, format, err := readPreferencesFile(path)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if values[key] == nil {
+			dict.Delete(key)
+			continue
+		}
+		v, err := objectValue(values[key])
+		if err != nil {
+			return err
+		}
+		dict.Set(key, v)
+	}
+	return writePreferencesFile(path, dict, format, userName)
+}
+
+func Preferences(key, appID, userName, hostName string) (interface{}, error) {
+	path, err := preferencesPath(appID, userName, hostName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed Preferences(%s)", key)
+	}
+
+	preferencesLock.Lock()
+	defer preferencesLock.Unlock()
+
+	dict, _, err := readPreferencesFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed Preferences(%s)", key)
+	}
+	if v, ok := dict.Get(key); ok {
+		return v.Interface(), nil
+	}
+	return nil, nil
+}
+
+func PreferencesSet(key string, value interface{}, appID string, userName string, hostName string) error {
+	err := updatePreferences([]string{key}, map[string]interface{}{key: value}, appID, userName, hostName)
+	return errors.Wrapf(err, "failed PreferencesSet(%s)", key)
+}
+
+func PreferencesSetMulti(keys map[string]interface{}, appID string, userName string, hostName string) error {
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return errors.Wrap(updatePreferences(names, keys, appID, userName, hostName), "failed PreferencesSetMulti")
+}
+
+func PreferencesKeyList(appID, userName, hostName string) ([]string, error) {
+	path, err := preferencesPath(appID, userName, hostName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesKeyList")
+	}
+
+	preferencesLock.Lock()
+	defer preferencesLock.Unlock()
+
+	dict, _, err := readPreferencesFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesKeyList")
+	}
+	if len(dict) == 0 {
+		return nil, nil
+	}
+	return dict.Keys(), nil
+}
+
+func PreferencesMulti(keys []string, appID, userName, hostName string) (map[string]interface{}, error) {
+	path, err := preferencesPath(appID, userName, hostName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesMulti")
+	}
+
+	preferencesLock.Lock()
+	defer preferencesLock.Unlock()
+
+	dict, _, err := readPreferencesFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesMulti")
+	}
+	if keys == nil {
+		return dict.Interface().(map[string]interface{}), nil
+	}
+	out := map[string]interface{}{}
+	for _, key := range keys {
+		if v, ok := dict.Get(key); ok {
+			out[key] = v.Interface()
+		}
+	}
+	return out, nil
+}
+
+// PreferencesValues returns all keys of the domain as Values, exactly as they
+// are stored in the file.
+func PreferencesValues(appID, userName, hostName string) (Dict, error) {
+	path, err := preferencesPath(appID, userName, hostName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesValues")
+	}
+
+	preferencesLock.Lock()
+	defer preferencesLock.Unlock()
+
+	dict, _, err := readPreferencesFile(path)
+	return dict, errors.Wrap(err, "failed PreferencesValues")
+}
+
+// PreferencesApplicationList lists the preference files in the directory of
+// the user and host.
+func PreferencesApplicationList(userName, hostName string) ([]string, error) {
+	// the path of a placeholder domain gives the directory and file name suffix
+	placeholder, err := preferencesPath("-", userName, hostName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesApplicationList")
+	}
+	dir, suffix := filepath.Dir(placeholder), strings.TrimPrefix(filepath.Base(placeholder), "-")
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed PreferencesApplicationList")
+	}
+	var apps []string
+	for _, fi := range files {
+		name := fi.Name()
+		if !fi.Mode().IsRegular() || !strings.HasSuffix(name, suffix) || len(name) == len(suffix) {
+			continue
+		}
+		app := strings.TrimSuffix(name, suffix)
+		if app == globalPreferencesName {
+			app = PreferencesAnyApplication
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+// PreferencesRemove removes key from the domain and reports whether it was
+// set.
+func PreferencesRemove(key, appID, userName, hostName string) (bool, error) {
+	path, err := preferencesPath(appID, userName, hostName)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed PreferencesRemove(%s)", key)
+	}
+
+	preferencesLock.Lock()
+	defer preferencesLock.Unlock()
+
+	dict, format, err := readPreferencesFile(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed PreferencesRemove(%s)", key)
+	}
+	if !dict.Delete(key) {
+		return false, nil
+	}
+	return true, errors.Wrapf(writePreferencesFile(path, dict, format, userName), "failed PreferencesRemove(%s)", key)
+}
+
+// PreferencesRemoveDomain removes all keys from the domain and reports whether
+// there were any. The file of the domain is removed if removeFile is set, and
+// left empty otherwise; a missing file is not created.
+func PreferencesRemoveDomain(appID, userName, hostName string, removeFile bool) (bool, error) {
+	path, err := preferencesPath(appID, userName, hostName)
+	if err != nil {
+		return false, errors.Wrap(err, "failed PreferencesRemoveDomain")
+	}
+
+	preferencesLock.Lock()
+	defer preferencesLock.Unlock()
+
+	dict, format, err := readPreferencesFile(path)
+	if err != nil {
+		return false, errors.Wrap(err, "failed PreferencesRemoveDomain")
+	}
+	if removeFile {
+		err = writePreferencesFile(path, Dict{}, format, userName)
+	} else if _, statErr := os.Stat(path); statErr == nil {
+		err = replacePreferencesFile(path, Dict{}, format, userName)
+	}
+	return len(dict) > 0, errors.Wrap(err, "failed PreferencesRemoveDomain")
+}
+
+// PreferencesSynchronize only validates the domain, as changes are written to
+// the files right away.
+func PreferencesSynchronize(appID, userName, hostName string) (bool, error) {
+	if _, err := preferencesPath(appID, userName, hostName); err != nil {
+		return false, errors.Wrap(err, "failed PreferencesSynchronize")
+	}
+	return true, nil
+}
+
+// managedPreferences returns the value of key in the managed preferences of
+// appID, or nil
+func managedPreferences(key, appID string) (interface{}, error) {
+	if appID != PreferencesAnyApplication {
+		if err := checkPreferencesName("application ID", appID); err != nil {
+			return nil, err
+		}
+	}
+	dir := filepath.Join(PreferencesRoot, "Library", "Managed Preferences")
+	names := []string{appID, globalPreferencesName}
+	if appID == PreferencesAnyApplication {
+		names = names[1:]
+	}
+	for _, name := range names {
+		for _, path := range []string{
+			filepath.Join(dir, filepath.Base(PreferencesHome), name+".plist"),
+			filepath.Join(dir, name+".plist"),
+		} {
+			dict, _, err := readPreferencesFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if v, ok := dict.Get(key); ok {
+				return v.Interface(), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// PreferencesApp looks key up in the managed preferences of appID and then in
+// the domains of PreferencesSearchList.
+func PreferencesApp(key, appID string) (interface{}, error) {
+	if appID == "" {
+		return nil, errors.Errorf("failed PreferencesApp(%s): empty application ID", key)
+	}
+
+	v, err := managedPreferences(key, appID)
+	if err != nil || v != nil {
+		return v, errors.Wrapf(err, "failed PreferencesApp(%s)", key)
+	}
+	for _, d := range PreferencesSearchList(appID) {
+		if v, err = Preferences(key, d.Application, d.User, d.Host); err != nil || v != nil {
+			return v, errors.Wrapf(err, "failed PreferencesApp(%s)", key)
+		}
+	}
+	return nil, nil
+}
+
+func PreferencesAppSynchronize(appID string) (bool, error) {
+	if appID == "" {
+		return false, errors.New("failed PreferencesAppSynchronize: empty application ID")
+	}
+	return true, nil
+}
+
+func PreferencesAppValueIsForced(key, appID string) (bool, error) {
+	if appID == "" {
+		return false, errors.Errorf("failed PreferencesAppValueIsForced(%s): empty application ID", key)
+	}
+	v, err := managedPreferences(key, appID)
+	return v != nil, errors.Wrapf(err, "failed PreferencesAppValueIsForced(%s)", key)
+}
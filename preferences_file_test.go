@@ -0,0 +1,244 @@
+//go:build !darwin
+// +build !darwin
+
+package cf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// withPreferencesRoot points the file-backed preferences at a temporary
+// directory for the duration of a test
+func withPreferencesRoot(t *testing.T) string {
+	root, err := ioutil.TempDir("", "cf-prefs")
+	require.NoError(t, err)
+	oldRoot, oldHome, oldUUID := PreferencesRoot, PreferencesHome, PreferencesHostUUID
+	PreferencesRoot, PreferencesHome, PreferencesHostUUID = root, "/Users/me", "00000000-0000-0000-0000-000000000001"
+	t.Cleanup(func() {
+		PreferencesRoot, PreferencesHome, PreferencesHostUUID = oldRoot, oldHome, oldUUID
+		os.RemoveAll(root)
+	})
+	return root
+}
+
+func TestFilePreferencesPaths(t *testing.T) {
+	root := withPreferencesRoot(t)
+
+	for path, domain := range map[string][3]string{
+		"Users/me/Library/Preferences/com.example.plist": {"com.example", PreferencesCurrentUser, PreferencesAnyHost},
+		"Users/me/Library/Preferences/ByHost/com.example.00000000-0000-0000-0000-000000000001.plist": {
+			"com.example", PreferencesCurrentUser, PreferencesCurrentHost},
+		"Users/me/Library/Preferences/.GlobalPreferences.plist": {
+			PreferencesAnyApplication, PreferencesCurrentUser, PreferencesAnyHost},
+		"Library/Preferences/com.example.plist":             {"com.example", PreferencesAnyUser, PreferencesAnyHost},
+		"Library/Preferences/.GlobalPreferences.plist":      {PreferencesAnyApplication, PreferencesAnyUser, PreferencesAnyHost},
+		"Users/other/Library/Preferences/com.example.plist": {"com.example", "other", PreferencesAnyHost},
+	} {
+		require.NoError(t, PreferencesSet("key", "value", domain[0], domain[1], domain[2]))
+		data, err := ioutil.ReadFile(filepath.Join(root, path))
+		require.NoError(t, err, path)
+		require.True(t, bytes.HasPrefix(data, []byte("bplist00")), path)
+		v, err := DecodeBinaryPlist(data)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{"key": "value"}, v)
+	}
+
+	require.Error(t, PreferencesSet("key", "value", "com.example", PreferencesCurrentUser, "host.local"))
+	require.Error(t, PreferencesSet("key", "value", "", PreferencesCurrentUser, PreferencesAnyHost))
+	_, err := PreferencesSynchronize("com.example", "", PreferencesAnyHost)
+	require.Error(t, err)
+}
+
+func TestFilePreferencesPathTraversal(t *testing.T) {
+	root := withPreferencesRoot(t)
+	outside := filepath.Join(filepath.Dir(root), "evil.plist")
+
+	for _, name := range []string{"../../../../evil", "..", ".", `..\evil`, "a/b", "a\x00b"} {
+		_, err := preferencesPath(name, PreferencesCurrentUser, PreferencesAnyHost)
+		require.Error(t, err, name)
+		_, err = preferencesPath("com.example", name, PreferencesAnyHost)
+		require.Error(t, err, name)
+		require.Error(t, PreferencesSet("key", "value", name, PreferencesCurrentUser, PreferencesAnyHost), name)
+		require.Error(t, PreferencesSet("key", "value", "com.example", name, PreferencesAnyHost), name)
+		_, err = PreferencesRemoveDomain(name, PreferencesAnyUser, PreferencesAnyHost, true)
+		require.Error(t, err, name)
+		_, err = PreferencesApp("key", name)
+		require.Error(t, err, name)
+	}
+	_, err := os.Stat(outside)
+	require.True(t, os.IsNotExist(err))
+
+	// dots inside names are fine
+	_, err = preferencesPath("com.example..app", "first.last", PreferencesAnyHost)
+	require.NoError(t, err)
+}
+
+func TestFilePreferences(t *testing.T) {
+	withPreferencesRoot(t)
+
+	v, err := Preferences("a", "raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	require.NoError(t, PreferencesSet("a", "aval", "raar", PreferencesCurrentUser, PreferencesAnyHost))
+	require.NoError(t, PreferencesSet("b", "bval", "raar", PreferencesCurrentUser, PreferencesAnyHost))
+	require.NoError(t, PreferencesSetMulti(map[string]interface{}{"a": "aval2", "b": nil, "c": int64(3)},
+		"raar", PreferencesCurrentUser, PreferencesAnyHost))
+	ok, err := PreferencesSynchronize("raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	for key, expected := range map[string]interface{}{"a": "aval2", "b": nil, "c": int64(3)} {
+		v, err := Preferences(key, "raar", PreferencesCurrentUser, PreferencesAnyHost)
+		require.NoError(t, err)
+		require.Equal(t, expected, v, key)
+	}
+
+	keys, err := PreferencesKeyList("raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "c"}, keys)
+
+	// other domains are separate
+	v, err = Preferences("a", "raar", PreferencesCurrentUser, PreferencesCurrentHost)
+	require.NoError(t, err)
+	require.Nil(t, v)
+}
+
+func TestFilePreferencesFormat(t *testing.T) {
+	root := withPreferencesRoot(t)
+	path := filepath.Join(root, "Library", "Preferences", "com.example.plist")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(xmlPlistHeader+"<dict><key>z</key><true/></dict>"+xmlPlistFooter), 0640))
+
+	require.NoError(t, PreferencesSet("a", "b", "com.example", PreferencesAnyUser, PreferencesAnyHost))
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, xmlPlistHeader+"<dict>\n\t<key>z</key>\n\t<true/>\n\t<key>a</key>\n\t<string>b</string>\n</dict>\n"+xmlPlistFooter, string(data))
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+
+	// removing the last key removes the file
+	require.NoError(t, PreferencesSetMulti(map[string]interface{}{"a": nil, "z": nil}, "com.example", PreferencesAnyUser, PreferencesAnyHost))
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("(1, 2)"), 0644))
+	_, err = Preferences("a", "com.example", PreferencesAnyUser, PreferencesAnyHost)
+	require.Error(t, err)
+}
+
+func TestFilePreferencesDomain(t *testing.T) {
+	root := withPreferencesRoot(t)
+
+	d := ByHostDomain("com.example")
+	require.NoError(t, d.SetMulti(map[string]interface{}{"a": "aval", "b": "bval"}))
+	removed, err := d.Remove("b")
+	require.NoError(t, err)
+	require.True(t, removed)
+	require.NoError(t, GlobalDomain().Set("AppleInterfaceStyle", "Dark"))
+	ok, err := d.Synchronize()
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	v, err := d.Value("a")
+	require.NoError(t, err)
+	require.Equal(t, "aval", v)
+	keys, err := d.KeyList()
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, keys)
+
+	_, err = os.Stat(filepath.Join(root, "Users/me/Library/Preferences/.GlobalPreferences.plist"))
+	require.NoError(t, err)
+}
+
+func TestFilePreferencesMulti(t *testing.T) {
+	withPreferencesRoot(t)
+
+	require.NoError(t, PreferencesSetMulti(map[string]interface{}{"a": "aval", "b": int64(2)},
+		"raar", PreferencesCurrentUser, PreferencesAnyHost))
+	require.NoError(t, PreferencesSet("c", true, "raar", PreferencesCurrentUser, PreferencesCurrentHost))
+	require.NoError(t, PreferencesSet("d", true, PreferencesAnyApplication, PreferencesCurrentUser, PreferencesAnyHost))
+	require.NoError(t, PreferencesSet("e", true, "other", PreferencesAnyUser, PreferencesAnyHost))
+
+	values, err := PreferencesMulti(nil, "raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": "aval", "b": int64(2)}, values)
+	values, err = PreferencesMulti([]string{"b", "missing"}, "raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"b": int64(2)}, values)
+	values, err = PreferencesMulti(nil, "missing", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Empty(t, values)
+
+	require.NoError(t, PreferencesSet("r", Real{2, 8}, "raar", PreferencesCurrentUser, PreferencesAnyHost))
+	dict, err := PreferencesValues("raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, Dict{{"a", String("aval")}, {"b", Integer{2, true, 8}}, {"r", Real{2, 8}}}, dict)
+	dict, err = PreferencesValues("missing", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Empty(t, dict)
+
+	apps, err := PreferencesApplicationList(PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{PreferencesAnyApplication, "raar"}, apps)
+	apps, err = PreferencesApplicationList(PreferencesCurrentUser, PreferencesCurrentHost)
+	require.NoError(t, err)
+	require.Equal(t, []string{"raar"}, apps)
+	apps, err = PreferencesApplicationList("nobody", PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Empty(t, apps)
+
+	domains, err := Domains(PreferencesAnyUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, []Domain{SystemDomain("other")}, domains)
+	values, err = UserDomain("raar").Values("a")
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": "aval"}, values)
+}
+
+func TestFilePreferencesRemove(t *testing.T) {
+	root := withPreferencesRoot(t)
+	path := filepath.Join(root, "Users/me/Library/Preferences/com.example.plist")
+
+	require.NoError(t, PreferencesSetMulti(map[string]interface{}{"a": "aval", "b": "bval"},
+		"com.example", PreferencesCurrentUser, PreferencesAnyHost))
+	removed, err := PreferencesRemove("a", "com.example", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.True(t, removed)
+	removed, err = PreferencesRemove("a", "com.example", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.False(t, removed)
+
+	// the file is kept, empty
+	removed, err = PreferencesRemoveDomain("com.example", PreferencesCurrentUser, PreferencesAnyHost, false)
+	require.NoError(t, err)
+	require.True(t, removed)
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	v, err := DecodeBinaryPlist(data)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{}, v)
+
+	removed, err = PreferencesRemoveDomain("com.example", PreferencesCurrentUser, PreferencesAnyHost, true)
+	require.NoError(t, err)
+	require.False(t, removed)
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	// a missing file is not created
+	removed, err = PreferencesRemoveDomain("com.example", PreferencesCurrentUser, PreferencesAnyHost, false)
+	require.NoError(t, err)
+	require.False(t, removed)
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	_, err = PreferencesRemove("a", "com.example", PreferencesCurrentUser, "host.local")
+	require.Error(t, err)
+}
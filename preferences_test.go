@@ -1,3 +1,6 @@
+//go:build darwin
+// +build darwin
+
 package cf
 
 import (
@@ -65,3 +68,36 @@ func TestMulti(t *testing.T) {
 		panic("wrong c")
 	}
 }
+
+func TestMultiCopy(t *testing.T) {
+	err := PreferencesSetMulti(map[string]interface{}{"a": "aval", "b": "bval"},
+		"raar", PreferencesCurrentUser, PreferencesAnyHost)
+	if err != nil {
+		panic(err)
+	}
+	v, err := PreferencesMulti([]string{"a", "missing"}, "raar", PreferencesCurrentUser, PreferencesAnyHost)
+	if err != nil {
+		panic(err)
+	}
+	if len(v) != 1 || v["a"] != "aval" {
+		panic("wrong values")
+	}
+	v, err = PreferencesMulti(nil, "raar", PreferencesCurrentUser, PreferencesAnyHost)
+	if err != nil {
+		panic(err)
+	}
+	if v["b"] != "bval" {
+		panic("wrong b")
+	}
+	apps, err := PreferencesApplicationList(PreferencesCurrentUser, PreferencesAnyHost)
+	if err != nil {
+		panic(err)
+	}
+	found := false
+	for _, app := range apps {
+		found = found || app == "raar"
+	}
+	if !found {
+		panic("raar not listed")
+	}
+}
@@ -0,0 +1,315 @@
+package cf
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Snapshot archives.
+//
+// A snapshot is stored as a directory, or a tar archive of one, holding
+// manifest.json and a binary plist with the values of every domain:
+//
+//	manifest.json
+//	domains/000-com.apple.dock.plist
+//	domains/001-kCFPreferencesAnyApplication.plist
+//
+// The manifest lists the domains in order with the names of their files:
+//
+//	{
+//	  "version": 1,
+//	  "created": "2020-01-02T03:04:05Z",
+//	  "domains": [
+//	    {"application": "com.apple.dock", "user": "kCFPreferencesCurrentUser",
+//	     "host": "kCFPreferencesAnyHost", "existed": true,
+//	     "file": "domains/000-com.apple.dock.plist"},
+//	    ...
+//	  ]
+//	}
+
+const (
+	snapshotVersion  = 1
+	snapshotManifest = "manifest.json"
+)
+
+// Snapshot is the state of a set of preference domains at one point in time.
+type Snapshot struct {
+	Created time.Time
+	Domains []DomainSnapshot
+}
+
+// DomainSnapshot is the state of one domain: whether it existed and the values
+// of all its keys, sorted by key. Values keep their exact types, so a real
+// holding a whole number stays a real and dates keep their precision.
+type DomainSnapshot struct {
+	Domain  Domain
+	Existed bool
+	Values  Dict
+}
+
+// InvalidSnapshotError is returned when a snapshot archive is malformed.
+type InvalidSnapshotError struct {
+	Reason string
+}
+
+func (e *InvalidSnapshotError) Error() string {
+	return "plist: invalid snapshot: " + e.Reason
+}
+
+type snapshotManifestJSON struct {
+	Version int                    `json:"version"`
+	Created time.Time              `json:"created"`
+	Domains []snapshotManifestItem `json:"domains"`
+}
+
+type snapshotManifestItem struct {
+	Domain
+	Existed bool   `json:"existed"`
+	File    string `json:"file"`
+}
+
+// TakeSnapshot captures the domains from store, or from the package-level
+// Preferences functions if store is nil. A domain exists if it is in the
+// ApplicationList of its user and host.
+func TakeSnapshot(store Store, domains ...Domain) (*Snapshot, error) {
+	if store == nil {
+		store = PreferencesStore{}
+	}
+	s := &Snapshot{Created: time.Now()}
+	for _, d := range domains {
+		if err := d.Validate(); err != nil {
+			return nil, err
+		}
+		apps, err := store.ApplicationList(d.User, d.Host)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to snapshot %s", d)
+		}
+		ds := DomainSnapshot{Domain: d}
+		for _, app := range apps {
+			ds.Existed = ds.Existed || app == d.Application
+		}
+		if ds.Values, err = store.GetValues(d.Application, d.User, d.Host); err != nil {
+			return nil, errors.Wrapf(err, "failed to snapshot %s", d)
+		}
+		sort.Slice(ds.Values, func(i, j int) bool { return ds.Values[i].Key < ds.Values[j].Key })
+		s.Domains = append(s.Domains, ds)
+	}
+	return s, nil
+}
+
+// Restore brings the domains back to their state in the snapshot: keys set
+// since are removed, and domains that did not exist are removed with their
+// files. Each domain is updated with one SetMulti and synchronized.
+func (s *Snapshot) Restore(store Store) error {
+	if store == nil {
+		store = PreferencesStore{}
+	}
+	for _, ds := range s.Domains {
+		d := ds.Domain
+		if err := d.validateStoreWrite(store); err != nil {
+			return err
+		}
+		if err := ds.restore(store); err != nil {
+			return errors.Wrapf(err, "failed to restore %s", d)
+		}
+		if _, err := store.Synchronize(d.Application, d.User, d.Host); err != nil {
+			return errors.Wrapf(err, "failed to restore %s", d)
+		}
+	}
+	return nil
+}
+
+func (ds DomainSnapshot) restore(store Store) error {
+	d := ds.Domain
+	if !ds.Existed {
+		_, err := store.RemoveDomain(d.Application, d.User, d.Host, true)
+		return err
+	}
+	if len(ds.Values) == 0 {
+		_, err := store.RemoveDomain(d.Application, d.User, d.Host, false)
+		return err
+	}
+
+	current, err := store.KeyList(d.Application, d.User, d.Host)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]interface{}, len(current)+len(ds.Values))
+	for _, key := range current {
+		keys[key] = nil
+	}
+	for _, e := range ds.Values {
+		keys[e.Key] = copyValue(e.Value)
+	}
+	return store.SetMulti(keys, d.Application, d.User, d.Host)
+}
+
+// snapshotFileName names the file of the i-th domain after its application,
+// keeping it readable and safe as a file name
+func snapshotFileName(i int, d Domain) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, d.Application)
+	return fmt.Sprintf("domains/%03d-%s.plist", i, name)
+}
+
+// files returns the files of the archive, the manifest first
+func (s *Snapshot) files() ([]string, map[string][]byte, error) {
+	m := snapshotManifestJSON{Version: snapshotVersion, Created: s.Created.UTC()}
+	names := []string{snapshotManifest}
+	files := map[string][]byte{}
+	for i, ds := range s.Domains {
+		name := snapshotFileName(i, ds.Domain)
+		values := ds.Values
+		if values == nil {
+			values = Dict{}
+		}
+		data, err := EncodeBinaryPlist(values)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to encode %s", ds.Domain)
+		}
+		m.Domains = append(m.Domains, snapshotManifestItem{ds.Domain, ds.Existed, name})
+		names = append(names, name)
+		files[name] = data
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	files[snapshotManifest] = append(data, '\n')
+	return names, files, nil
+}
+
+// parseSnapshot reads an archive with the files get returns
+func parseSnapshot(get func(name string) ([]byte, error)) (*Snapshot, error) {
+	data, err := get(snapshotManifest)
+	if err != nil {
+		return nil, err
+	}
+	var m snapshotManifestJSON
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, &InvalidSnapshotError{fmt.Sprintf("%s: %s", snapshotManifest, err)}
+	}
+	if m.Version != snapshotVersion {
+		return nil, &InvalidSnapshotError{fmt.Sprintf("unsupported version %d", m.Version)}
+	}
+
+	s := &Snapshot{Created: m.Created}
+	for _, item := range m.Domains {
+		if err := item.Domain.Validate(); err != nil {
+			return nil, &InvalidSnapshotError{err.Error()}
+		}
+		data, err := get(item.File)
+		if err != nil {
+			return nil, err
+		}
+		v, err := DecodeBinaryPlistValue(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", item.File)
+		}
+		values, ok := v.(Dict)
+		if !ok {
+			return nil, &InvalidSnapshotError{item.File + " does not contain a dictionary"}
+		}
+		s.Domains = append(s.Domains, DomainSnapshot{item.Domain, item.Existed, values})
+	}
+	return s, nil
+}
+
+// WriteDir writes the snapshot to dir, which is created if needed.
+func (s *Snapshot) WriteDir(dir string) error {
+	names, files, err := s.files()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "domains"), 0700); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), files[name], 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadSnapshotDir reads a snapshot written by WriteDir.
+func ReadSnapshotDir(dir string) (*Snapshot, error) {
+	return parseSnapshot(func(name string) ([]byte, error) {
+		if !isSnapshotFileName(name) {
+			return nil, &InvalidSnapshotError{fmt.Sprintf("invalid file name %q", name)}
+		}
+		return ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	})
+}
+
+// WriteTar writes the snapshot as a tar archive.
+func (s *Snapshot) WriteTar(w io.Writer) error {
+	names, files, err := s.files()
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(w)
+	for _, name := range names {
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0600,
+			Size:     int64(len(files[name])),
+			ModTime:  s.Created,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// ReadSnapshotTar reads a snapshot written by WriteTar.
+func ReadSnapshotTar(r io.Reader) (*Snapshot, error) {
+	files := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if files[hdr.Name], err = ioutil.ReadAll(tr); err != nil {
+			return nil, err
+		}
+	}
+	return parseSnapshot(func(name string) ([]byte, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, &InvalidSnapshotError{name + " is missing"}
+		}
+		return data, nil
+	})
+}
+
+// isSnapshotFileName rejects manifest entries pointing outside the archive
+func isSnapshotFileName(name string) bool {
+	return name != "" && !filepath.IsAbs(name) && !strings.HasPrefix(filepath.Clean(filepath.FromSlash(name)), "..")
+}
@@ -0,0 +1,146 @@
+package cf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	store := &MemoryStore{}
+	dock, global, fresh := UserDomain("com.apple.dock"), GlobalDomain(), UserDomain("com.example")
+	require.NoError(t, store.SetMulti(map[string]interface{}{
+		"tilesize":  int64(36),
+		"autohide":  true,
+		"mod-count": uint64(1 << 63),
+		"date":      time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		"apps":      []interface{}{map[string]interface{}{"tile-type": "file-tile"}},
+	}, dock.Application, dock.User, dock.Host))
+	require.NoError(t, store.Set("AppleInterfaceStyle", "Dark", global.Application, global.User, global.Host))
+
+	s, err := TakeSnapshot(store, dock, global, fresh)
+	require.NoError(t, err)
+	require.True(t, s.Domains[0].Existed)
+	require.False(t, s.Domains[2].Existed)
+	before, err := store.GetMulti(nil, dock.Application, dock.User, dock.Host)
+	require.NoError(t, err)
+
+	require.NoError(t, store.SetMulti(map[string]interface{}{"tilesize": int64(48), "autohide": nil, "new": "x"},
+		dock.Application, dock.User, dock.Host))
+	_, err = store.RemoveDomain(global.Application, global.User, global.Host, true)
+	require.NoError(t, err)
+	require.NoError(t, store.Set("a", "b", fresh.Application, fresh.User, fresh.Host))
+
+	require.NoError(t, s.Restore(store))
+	after, err := store.GetMulti(nil, dock.Application, dock.User, dock.Host)
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+	v, err := store.Get("AppleInterfaceStyle", global.Application, global.User, global.Host)
+	require.NoError(t, err)
+	require.Equal(t, "Dark", v)
+	keys, err := store.KeyList(fresh.Application, fresh.User, fresh.Host)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+	require.Equal(t, 1, store.SynchronizeCount(dock.Application, dock.User, dock.Host))
+}
+
+func TestSnapshotRestoreStoreAccess(t *testing.T) {
+	isRoot := preferencesIsRoot
+	defer func() { preferencesIsRoot = isRoot }()
+	preferencesIsRoot = func() bool { return false }
+
+	// only the package-level Preferences functions need root for other users
+	store := &MemoryStore{}
+	system := SystemDomain("com.example")
+	require.NoError(t, store.Set("a", "b", system.Application, system.User, system.Host))
+	s, err := TakeSnapshot(store, system)
+	require.NoError(t, err)
+	require.NoError(t, store.Set("a", "c", system.Application, system.User, system.Host))
+	require.NoError(t, s.Restore(store))
+	v, err := store.Get("a", system.Application, system.User, system.Host)
+	require.NoError(t, err)
+	require.Equal(t, "b", v)
+
+	require.IsType(t, &InvalidDomainError{}, s.Restore(PreferencesStore{}))
+}
+
+func TestSnapshotExactValues(t *testing.T) {
+	store := &MemoryStore{}
+	d := UserDomain("com.apple.dock")
+	date := dateFromAbsoluteTime(6e8 + 0.0001234)
+	require.NoError(t, store.SetMulti(map[string]interface{}{"tilesize": Real{48, 8}, "date": date},
+		d.Application, d.User, d.Host))
+	s, err := TakeSnapshot(store, d)
+	require.NoError(t, err)
+
+	requireExact := func(values Dict) {
+		require.Len(t, values, 2)
+		require.Equal(t, "date", values[0].Key)
+		require.IsType(t, Date{}, values[0].Value)
+		require.Equal(t, date.absoluteTime(), values[0].Value.(Date).absoluteTime())
+		require.Equal(t, DictEntry{"tilesize", Real{48, 8}}, values[1])
+	}
+	requireExact(s.Domains[0].Values)
+
+	dir, err := ioutil.TempDir("", "cf-snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, s.WriteDir(dir))
+	read, err := ReadSnapshotDir(dir)
+	require.NoError(t, err)
+	requireExact(read.Domains[0].Values)
+
+	var buf bytes.Buffer
+	require.NoError(t, s.WriteTar(&buf))
+	read, err = ReadSnapshotTar(&buf)
+	require.NoError(t, err)
+	requireExact(read.Domains[0].Values)
+
+	restored := &MemoryStore{}
+	require.NoError(t, read.Restore(restored))
+	values, err := restored.GetValues(d.Application, d.User, d.Host)
+	require.NoError(t, err)
+	requireExact(values)
+}
+
+func TestSnapshotArchive(t *testing.T) {
+	store := &MemoryStore{}
+	require.NoError(t, store.SetMulti(map[string]interface{}{"a": "b", "n": int64(-1), "d": []byte{1}},
+		"com.example\tx", PreferencesCurrentUser, PreferencesCurrentHost))
+	s, err := TakeSnapshot(store, ByHostDomain("com.example\tx"), SystemDomain("com.example"))
+	require.NoError(t, err)
+	s.Created = s.Created.Round(0).UTC()
+
+	dir, err := ioutil.TempDir("", "cf-snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, s.WriteDir(dir))
+	_, err = os.Stat(filepath.Join(dir, "domains", "000-com.example_x.plist"))
+	require.NoError(t, err)
+	read, err := ReadSnapshotDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, s, read)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, s.WriteTar(buf))
+	read, err = ReadSnapshotTar(buf)
+	require.NoError(t, err)
+	require.Equal(t, s, read)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "manifest.json"), []byte(`{"version": 2}`), 0600))
+	_, err = ReadSnapshotDir(dir)
+	require.IsType(t, &InvalidSnapshotError{}, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "manifest.json"),
+		[]byte(`{"version": 1, "domains": [{"application": "a", "user": "kCFPreferencesCurrentUser", "host": "kCFPreferencesAnyHost", "file": "../x"}]}`), 0600))
+	_, err = ReadSnapshotDir(dir)
+	require.IsType(t, &InvalidSnapshotError{}, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "manifest.json"),
+		[]byte(`{"version": 1, "domains": [{"application": "../../x", "user": "kCFPreferencesCurrentUser", "host": "kCFPreferencesAnyHost", "file": "domains/000-com.example_x.plist"}]}`), 0600))
+	_, err = ReadSnapshotDir(dir)
+	require.IsType(t, &InvalidSnapshotError{}, err)
+}
@@ -0,0 +1,315 @@
+package cf
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Desired-state documents.
+//
+// A desired-state document lists preference domains and the values their keys
+// should have. It is a property list in any format Decode reads, usually JSON:
+//
+//	{
+//	  "domains": [
+//	    {
+//	      "application": "com.apple.dock",
+//	      "values": {
+//	        "autohide": true,
+//	        "tilesize": 48,
+//	        "recent-apps": {"$absent": true}
+//	      }
+//	    },
+//	    {"application": "NSGlobalDomain", "host": "current", "values": {...}}
+//	  ]
+//	}
+//
+// "user" is "current" (the default), "any" or the name of a user, and "host"
+// is "any" (the default) or "current"; the CFPreferences constants are
+// accepted too. "NSGlobalDomain" names PreferencesAnyApplication. A
+// {"$absent": true} dictionary marks a key that must not be set.
+
+const desiredStateAbsent = "$absent"
+
+// DesiredState is a loaded desired-state document.
+type DesiredState struct {
+	Domains []DesiredDomain
+}
+
+// DesiredDomain is the desired state of one domain.
+type DesiredDomain struct {
+	Domain Domain
+	// Values holds the desired values of keys, in document order
+	Values Dict
+	// Absent lists the keys that must not be set
+	Absent []string
+}
+
+// InvalidDesiredStateError is returned for malformed desired-state documents.
+type InvalidDesiredStateError struct {
+	Reason string
+}
+
+func (e *InvalidDesiredStateError) Error() string {
+	return "plist: invalid desired state: " + e.Reason
+}
+
+// LoadDesiredState reads a desired-state document.
+func LoadDesiredState(r io.Reader) (*DesiredState, error) {
+	v, _, err := DecodeValue(r)
+	if err != nil {
+		return nil, err
+	}
+	fail := func(format string, args ...interface{}) error {
+		return &InvalidDesiredStateError{fmt.Sprintf(format, args...)}
+	}
+
+	doc, ok := v.(Dict)
+	if !ok {
+		return nil, fail("document is not a dictionary")
+	}
+	state := &DesiredState{}
+	for _, e := range doc {
+		if e.Key != "domains" {
+			return nil, fail("unknown key %q", e.Key)
+		}
+		domains, ok := e.Value.(Array)
+		if !ok {
+			return nil, fail("domains is not an array")
+		}
+		for i, dv := range domains {
+			d, err := loadDesiredDomain(dv)
+			if err != nil {
+				return nil, fail("domain %d: %s", i, err.(*InvalidDesiredStateError).Reason)
+			}
+			state.Domains = append(state.Domains, d)
+		}
+	}
+	return state, nil
+}
+
+func loadDesiredDomain(v Value) (DesiredDomain, error) {
+	fail := func(format string, args ...interface{}) error {
+		return &InvalidDesiredStateError{fmt.Sprintf(format, args...)}
+	}
+	entry, ok := v.(Dict)
+	if !ok {
+		return DesiredDomain{}, fail("not a dictionary")
+	}
+
+	d := DesiredDomain{Domain: Domain{"", PreferencesCurrentUser, PreferencesAnyHost}}
+	for _, e := range entry {
+		if e.Key == "values" {
+			values, ok := e.Value.(Dict)
+			if !ok {
+				return DesiredDomain{}, fail("values is not a dictionary")
+			}
+			for _, kv := range values {
+				if isDesiredStateAbsent(kv.Value) {
+					d.Absent = append(d.Absent, kv.Key)
+				} else {
+					d.Values = append(d.Values, kv)
+				}
+			}
+			continue
+		}
+
+		s, ok := e.Value.(String)
+		if !ok {
+			return DesiredDomain{}, fail("%s is not a string", e.Key)
+		}
+		switch e.Key {
+		case "application":
+			d.Domain.Application = string(s)
+			if s == "NSGlobalDomain" {
+				d.Domain.Application = PreferencesAnyApplication
+			}
+		case "user":
+			switch s {
+			case "current":
+				d.Domain.User = PreferencesCurrentUser
+			case "any":
+				d.Domain.User = PreferencesAnyUser
+			default:
+				d.Domain.User = string(s)
+			}
+		case "host":
+			switch s {
+			case "current":
+				d.Domain.Host = PreferencesCurrentHost
+			case "any":
+				d.Domain.Host = PreferencesAnyHost
+			default:
+				d.Domain.Host = string(s)
+			}
+		default:
+			return DesiredDomain{}, fail("unknown key %q", e.Key)
+		}
+	}
+	if err := d.Domain.Validate(); err != nil {
+		return DesiredDomain{}, fail("%s", err)
+	}
+	return d, nil
+}
+
+func isDesiredStateAbsent(v Value) bool {
+	dict, ok := v.(Dict)
+	if !ok || len(dict) != 1 || dict[0].Key != desiredStateAbsent {
+		return false
+	}
+	absent, ok := dict[0].Value.(Bool)
+	return ok && bool(absent)
+}
+
+// StatePlan holds the changes that bring domains to their desired state.
+type StatePlan struct {
+	Domains []DomainPlan
+}
+
+// DomainPlan holds the changes of one domain, in document order.
+type DomainPlan struct {
+	Domain  Domain
+	Changes []PreferencesChange
+}
+
+// Plan compares the desired state with the current values in store, or in the
+// package-level Preferences functions if store is nil. Domains that are in the
+// desired state already are left out of the plan. Numbers are compared by
+// value, so 48 in the document matches a stored 48.0.
+func (s *DesiredState) Plan(store Store) (*StatePlan, error) {
+	if store == nil {
+		store = PreferencesStore{}
+	}
+	plan := &StatePlan{}
+	for _, desired := range s.Domains {
+		d := desired.Domain
+		if err := d.Validate(); err != nil {
+			return nil, err
+		}
+		keys := append(desired.Values.Keys(), desired.Absent...)
+		current, err := store.GetMulti(keys, d.Application, d.User, d.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		dp := DomainPlan{Domain: d}
+		for _, e := range desired.Values {
+			v := e.Value.Interface()
+			patch, err := diffNumbers(current[e.Key], v)
+			if err != nil {
+				return nil, err
+			}
+			if len(patch) > 0 {
+				dp.Changes = append(dp.Changes, PreferencesChange{e.Key, current[e.Key], v})
+			}
+		}
+		for _, key := range desired.Absent {
+			if old, ok := current[key]; ok {
+				dp.Changes = append(dp.Changes, PreferencesChange{key, old, nil})
+			}
+		}
+		if len(dp.Changes) > 0 {
+			plan.Domains = append(plan.Domains, dp)
+		}
+	}
+	return plan, nil
+}
+
+// Empty reports whether the plan has no changes.
+func (p *StatePlan) Empty() bool {
+	return len(p.Domains) == 0
+}
+
+// String formats the plan for people: the domains with one line per change,
+// "+" for keys that are added, "~" for changed and "-" for removed ones, and
+// values in the JSON form of property lists.
+//
+//	{com.apple.dock kCFPreferencesCurrentUser kCFPreferencesAnyHost}
+//	  ~ tilesize: 36 -> 48
+//	  + autohide: true
+//	  - recent-apps: [...]
+func (p *StatePlan) String() string {
+	if p.Empty() {
+		return "No changes.\n"
+	}
+	var sb strings.Builder
+	for _, dp := range p.Domains {
+		fmt.Fprintf(&sb, "%s\n", dp.Domain)
+		for _, c := range dp.Changes {
+			switch {
+			case c.Old == nil:
+				fmt.Fprintf(&sb, "  + %s: %s\n", c.Key, formatJSONPlistValue(c.New))
+			case c.New == nil:
+				fmt.Fprintf(&sb, "  - %s: %s\n", c.Key, formatJSONPlistValue(c.Old))
+			default:
+				fmt.Fprintf(&sb, "  ~ %s: %s -> %s\n", c.Key, formatJSONPlistValue(c.Old), formatJSONPlistValue(c.New))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// ApplyReport is the outcome of applying a plan, one entry per domain of the
+// plan.
+type ApplyReport struct {
+	Domains []DomainReport `json:"domains"`
+	// Changed is the number of keys that were set or removed
+	Changed int `json:"changed"`
+	// Failed is the number of domains that could not be updated
+	Failed int `json:"failed"`
+}
+
+// DomainReport is the outcome of applying the changes of one domain.
+type DomainReport struct {
+	Domain       Domain              `json:"domain"`
+	Changes      []PreferencesChange `json:"changes"`
+	Synchronized bool                `json:"synchronized"`
+	Error        string              `json:"error,omitempty"`
+}
+
+// ApplyError is returned by StatePlan.Apply when some domains could not be
+// updated. The report tells which.
+type ApplyError struct {
+	Failed int
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("plist: failed to update %d preference domain(s)", e.Failed)
+}
+
+// Apply makes the changes of the plan in store, or with the package-level
+// Preferences functions if store is nil: one SetMulti and one Synchronize per
+// domain. A domain that fails does not stop the others; the report lists the
+// errors and Apply returns an *ApplyError.
+func (p *StatePlan) Apply(store Store) (*ApplyReport, error) {
+	if store == nil {
+		store = PreferencesStore{}
+	}
+	report := &ApplyReport{}
+	for _, dp := range p.Domains {
+		d := dp.Domain
+		r := DomainReport{Domain: d, Changes: dp.Changes}
+		err := d.validateStoreWrite(store)
+		if err == nil {
+			keys := make(map[string]interface{}, len(dp.Changes))
+			for _, c := range dp.Changes {
+				keys[c.Key] = c.New
+			}
+			err = store.SetMulti(keys, d.Application, d.User, d.Host)
+		}
+		if err == nil {
+			report.Changed += len(dp.Changes)
+			r.Synchronized, err = store.Synchronize(d.Application, d.User, d.Host)
+		}
+		if err != nil {
+			r.Error = err.Error()
+			report.Failed++
+		}
+		report.Domains = append(report.Domains, r)
+	}
+	if report.Failed > 0 {
+		return report, &ApplyError{report.Failed}
+	}
+	return report, nil
+}
@@ -0,0 +1,178 @@
+package cf
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+const desiredStateJSON = `{
+  "domains": [
+    {
+      "application": "com.apple.dock",
+      "values": {
+        "tilesize": 48,
+        "autohide": true,
+        "orientation": "bottom",
+        "recent-apps": {"$absent": true},
+        "missing": {"$absent": true}
+      }
+    },
+    {"application": "NSGlobalDomain", "host": "current", "values": {"AppleInterfaceStyle": "Dark"}},
+    {"application": "com.example", "user": "any", "values": {"a": "b"}}
+  ]
+}`
+
+func TestLoadDesiredState(t *testing.T) {
+	state, err := LoadDesiredState(strings.NewReader(desiredStateJSON))
+	require.NoError(t, err)
+	require.Len(t, state.Domains, 3)
+	require.Equal(t, UserDomain("com.apple.dock"), state.Domains[0].Domain)
+	require.Equal(t, []string{"tilesize", "autohide", "orientation"}, state.Domains[0].Values.Keys())
+	require.Equal(t, []string{"recent-apps", "missing"}, state.Domains[0].Absent)
+	require.Equal(t, ByHostDomain(PreferencesAnyApplication), state.Domains[1].Domain)
+	require.Equal(t, SystemDomain("com.example"), state.Domains[2].Domain)
+
+	// plists work too
+	state, err = LoadDesiredState(strings.NewReader(xmlPlistHeader + `<dict><key>domains</key><array><dict>
+		<key>application</key><string>com.apple.dock</string>
+		<key>values</key><dict><key>autohide</key><dict><key>$absent</key><true/></dict></dict>
+	</dict></array></dict>` + xmlPlistFooter))
+	require.NoError(t, err)
+	require.Equal(t, []string{"autohide"}, state.Domains[0].Absent)
+
+	for _, doc := range []string{
+		`[]`,
+		`{"domain": []}`,
+		`{"domains": {}}`,
+		`{"domains": [{"values": {}}]}`,
+		`{"domains": [{"application": "a", "host": "h"}]}`,
+		`{"domains": [{"application": "a", "valeus": {}}]}`,
+		`{"domains": [{"application": 1}]}`,
+		`{"domains": [{"application": "../../x", "values": {}}]}`,
+		`{"domains": [{"application": "a", "user": "../root", "values": {}}]}`,
+	} {
+		_, err := LoadDesiredState(strings.NewReader(doc))
+		require.IsType(t, &InvalidDesiredStateError{}, err, doc)
+	}
+}
+
+func TestStatePlanApply(t *testing.T) {
+	store := &MemoryStore{}
+	require.NoError(t, store.SetMulti(map[string]interface{}{"tilesize": 36, "autohide": true, "recent-apps": []string{"x"}},
+		"com.apple.dock", PreferencesCurrentUser, PreferencesAnyHost))
+	require.NoError(t, store.Set("AppleInterfaceStyle", "Dark", PreferencesAnyApplication, PreferencesCurrentUser, PreferencesCurrentHost))
+	store.ResetWrites()
+
+	state, err := LoadDesiredState(strings.NewReader(desiredStateJSON))
+	require.NoError(t, err)
+	state.Domains = state.Domains[:2]
+	plan, err := state.Plan(store)
+	require.NoError(t, err)
+	require.False(t, plan.Empty())
+	require.Equal(t, `{com.apple.dock kCFPreferencesCurrentUser kCFPreferencesAnyHost}
+  ~ tilesize: 36 -> 48
+  + orientation: "bottom"
+  - recent-apps: ["x"]
+`, plan.String())
+
+	report, err := plan.Apply(store)
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Changed)
+	require.Equal(t, 0, report.Failed)
+	require.True(t, report.Domains[0].Synchronized)
+	require.Len(t, store.Writes(), 3)
+	require.Equal(t, 1, store.SynchronizeCount("com.apple.dock", PreferencesCurrentUser, PreferencesAnyHost))
+	require.Equal(t, 0, store.SynchronizeCount(PreferencesAnyApplication, PreferencesCurrentUser, PreferencesCurrentHost))
+
+	data, err := json.Marshal(report)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"domains": [{
+		"domain": {"application": "com.apple.dock", "user": "kCFPreferencesCurrentUser", "host": "kCFPreferencesAnyHost"},
+		"changes": [
+			{"key": "tilesize", "old": 36, "new": 48},
+			{"key": "orientation", "new": "bottom"},
+			{"key": "recent-apps", "old": ["x"]}
+		],
+		"synchronized": true
+	}], "changed": 3, "failed": 0}`, string(data))
+
+	plan, err = state.Plan(store)
+	require.NoError(t, err)
+	require.True(t, plan.Empty())
+	require.Equal(t, "No changes.\n", plan.String())
+}
+
+// systemReadOnlyStore is a MemoryStore that fails writes to the domains of
+// all users
+type systemReadOnlyStore struct {
+	*MemoryStore
+}
+
+func (s systemReadOnlyStore) SetMulti(keys map[string]interface{}, appID, userName, hostName string) error {
+	if userName == PreferencesAnyUser {
+		return errors.New("read-only")
+	}
+	return s.MemoryStore.SetMulti(keys, appID, userName, hostName)
+}
+
+func TestStatePlanApplyFailure(t *testing.T) {
+	store := systemReadOnlyStore{&MemoryStore{}}
+	state, err := LoadDesiredState(strings.NewReader(desiredStateJSON))
+	require.NoError(t, err)
+	plan, err := state.Plan(store)
+	require.NoError(t, err)
+	require.Len(t, plan.Domains, 3)
+
+	report, err := plan.Apply(store)
+	require.IsType(t, &ApplyError{}, err)
+	require.Equal(t, 1, report.Failed)
+	require.Equal(t, 4, report.Changed)
+	require.NotEmpty(t, report.Domains[2].Error)
+	require.False(t, report.Domains[2].Synchronized)
+}
+
+func TestStatePlanApplyStoreAccess(t *testing.T) {
+	isRoot := preferencesIsRoot
+	defer func() { preferencesIsRoot = isRoot }()
+	preferencesIsRoot = func() bool { return false }
+
+	// only the package-level Preferences functions need root for other users
+	store := &MemoryStore{}
+	state, err := LoadDesiredState(strings.NewReader(desiredStateJSON))
+	require.NoError(t, err)
+	plan, err := state.Plan(store)
+	require.NoError(t, err)
+	report, err := plan.Apply(store)
+	require.NoError(t, err)
+	require.Equal(t, 0, report.Failed)
+	v, err := store.Get("a", "com.example", PreferencesAnyUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, "b", v)
+
+	plan = &StatePlan{Domains: []DomainPlan{{Domain: SystemDomain("com.example"),
+		Changes: []PreferencesChange{{Key: "a", New: "c"}}}}}
+	report, err = plan.Apply(PreferencesStore{})
+	require.IsType(t, &ApplyError{}, err)
+	require.Contains(t, report.Domains[0].Error, "requires root")
+}
+
+func TestStatePlanNumbers(t *testing.T) {
+	store := &MemoryStore{}
+	require.NoError(t, store.SetMulti(map[string]interface{}{"tilesize": 48.0, "autohide-delay": int64(0)},
+		"com.apple.dock", PreferencesCurrentUser, PreferencesAnyHost))
+	state, err := LoadDesiredState(strings.NewReader(
+		`{"domains": [{"application": "com.apple.dock", "values": {"tilesize": 48, "autohide-delay": 0.0}}]}`))
+	require.NoError(t, err)
+	plan, err := state.Plan(store)
+	require.NoError(t, err)
+	require.True(t, plan.Empty(), plan.String())
+
+	require.NoError(t, store.Set("tilesize", 48.5, "com.apple.dock", PreferencesCurrentUser, PreferencesAnyHost))
+	plan, err = state.Plan(store)
+	require.NoError(t, err)
+	require.Equal(t, "{com.apple.dock kCFPreferencesCurrentUser kCFPreferencesAnyHost}\n  ~ tilesize: 48.5 -> 48\n", plan.String())
+}
@@ -0,0 +1,78 @@
+package cf
+
+// Store is a preferences backend: the package-level Preferences functions, or
+// a MemoryStore in tests. Domains are named by an application ID, a user name
+// and a host name, as in the Preferences functions.
+type Store interface {
+	// Get returns the value of key, or nil if it is not set.
+	Get(key, appID, userName, hostName string) (interface{}, error)
+	// Set sets the value of key. A nil value removes the key.
+	Set(key string, value interface{}, appID, userName, hostName string) error
+	// SetMulti sets the values of several keys at once, removing the ones with
+	// nil values.
+	SetMulti(keys map[string]interface{}, appID, userName, hostName string) error
+	// Remove removes key and reports whether it was set.
+	Remove(key, appID, userName, hostName string) (bool, error)
+	// RemoveDomain removes all keys of the domain and reports whether there
+	// were any. File-backed stores also remove the file if removeFile is set.
+	RemoveDomain(appID, userName, hostName string, removeFile bool) (bool, error)
+	// Synchronize writes out pending changes and reads changes made by others.
+	Synchronize(appID, userName, hostName string) (bool, error)
+	// KeyList returns the keys set in the domain.
+	KeyList(appID, userName, hostName string) ([]string, error)
+	// GetMulti returns the values of keys, or of all keys if keys is nil.
+	// Keys that are not set are missing from the result.
+	GetMulti(keys []string, appID, userName, hostName string) (map[string]interface{}, error)
+	// GetValues returns all keys of the domain as Values, keeping the types
+	// that Get and GetMulti convert to the nearest Go type.
+	GetValues(appID, userName, hostName string) (Dict, error)
+	// ApplicationList returns the application IDs that have preferences for
+	// the user and host.
+	ApplicationList(userName, hostName string) ([]string, error)
+}
+
+// PreferencesStore is the Store of the package-level Preferences functions:
+// CFPreferences on darwin, and plist files in PreferencesRoot elsewhere.
+type PreferencesStore struct{}
+
+var _ Store = PreferencesStore{}
+
+func (PreferencesStore) Get(key, appID, userName, hostName string) (interface{}, error) {
+	return Preferences(key, appID, userName, hostName)
+}
+
+func (PreferencesStore) Set(key string, value interface{}, appID, userName, hostName string) error {
+	return PreferencesSet(key, value, appID, userName, hostName)
+}
+
+func (PreferencesStore) SetMulti(keys map[string]interface{}, appID, userName, hostName string) error {
+	return PreferencesSetMulti(keys, appID, userName, hostName)
+}
+
+func (PreferencesStore) Remove(key, appID, userName, hostName string) (bool, error) {
+	return PreferencesRemove(key, appID, userName, hostName)
+}
+
+func (PreferencesStore) RemoveDomain(appID, userName, hostName string, removeFile bool) (bool, error) {
+	return PreferencesRemoveDomain(appID, userName, hostName, removeFile)
+}
+
+func (PreferencesStore) Synchronize(appID, userName, hostName string) (bool, error) {
+	return PreferencesSynchronize(appID, userName, hostName)
+}
+
+func (PreferencesStore) KeyList(appID, userName, hostName string) ([]string, error) {
+	return PreferencesKeyList(appID, userName, hostName)
+}
+
+func (PreferencesStore) GetMulti(keys []string, appID, userName, hostName string) (map[string]interface{}, error) {
+	return PreferencesMulti(keys, appID, userName, hostName)
+}
+
+func (PreferencesStore) GetValues(appID, userName, hostName string) (Dict, error) {
+	return PreferencesValues(appID, userName, hostName)
+}
+
+func (PreferencesStore) ApplicationList(userName, hostName string) ([]string, error) {
+	return PreferencesApplicationList(userName, hostName)
+}
@@ -0,0 +1,195 @@
+package cf
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps preferences in memory, for tests. It
+// records every write and counts synchronizations so that tests can check what
+// the code under test did. The zero value is an empty store.
+//
+// Values are converted the way Marshal converts them when they are set, and
+// kept as Values: Get returns the types Preferences would return for them, and
+// GetValues the exact ones.
+type MemoryStore struct {
+	mu      sync.Mutex
+	domains map[memoryDomain]map[string]Value
+	writes  []MemoryWrite
+	syncs   map[memoryDomain]int
+}
+
+type memoryDomain struct {
+	appID, userName, hostName string
+}
+
+// MemoryWrite is a write recorded by MemoryStore. Value is nil for removals.
+// SetMulti records one write per key, in key order.
+type MemoryWrite struct {
+	Key      string
+	Value    interface{}
+	AppID    string
+	UserName string
+	HostName string
+}
+
+var _ Store = &MemoryStore{}
+
+func (s *MemoryStore) Get(key, appID, userName, hostName string) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.domains[memoryDomain{appID, userName, hostName}][key]; ok {
+		return deepCopy(v.Interface()), nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) Set(key string, value interface{}, appID, userName, hostName string) error {
+	return s.SetMulti(map[string]interface{}{key: value}, appID, userName, hostName)
+}
+
+func (s *MemoryStore) SetMulti(keys map[string]interface{}, appID, userName, hostName string) error {
+	names := make([]string, 0, len(keys))
+	values := make(map[string]Value, len(keys))
+	for key, value := range keys {
+		names = append(names, key)
+		if value == nil {
+			continue
+		}
+		// fail before storing anything, as CFPreferences does
+		v, err := objectValue(value)
+		if err != nil {
+			return err
+		}
+		values[key] = v
+	}
+	sort.Strings(names)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := memoryDomain{appID, userName, hostName}
+	if s.domains == nil {
+		s.domains = map[memoryDomain]map[string]Value{}
+	}
+	if s.domains[d] == nil {
+		s.domains[d] = map[string]Value{}
+	}
+	for _, key := range names {
+		var written interface{}
+		if v, ok := values[key]; ok {
+			s.domains[d][key] = v
+			written = deepCopy(v.Interface())
+		} else {
+			delete(s.domains[d], key)
+		}
+		s.writes = append(s.writes, MemoryWrite{key, written, appID, userName, hostName})
+	}
+	return nil
+}
+
+func (s *MemoryStore) Remove(key, appID, userName, hostName string) (bool, error) {
+	s.mu.Lock()
+	_, ok := s.domains[memoryDomain{appID, userName, hostName}][key]
+	s.mu.Unlock()
+	return ok, s.SetMulti(map[string]interface{}{key: nil}, appID, userName, hostName)
+}
+
+// RemoveDomain removes all keys of the domain, recording a write for each.
+func (s *MemoryStore) RemoveDomain(appID, userName, hostName string, removeFile bool) (bool, error) {
+	keys, _ := s.KeyList(appID, userName, hostName)
+	remove := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		remove[key] = nil
+	}
+	return len(keys) > 0, s.SetMulti(remove, appID, userName, hostName)
+}
+
+func (s *MemoryStore) Synchronize(appID, userName, hostName string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.syncs == nil {
+		s.syncs = map[memoryDomain]int{}
+	}
+	s.syncs[memoryDomain{appID, userName, hostName}]++
+	return true, nil
+}
+
+func (s *MemoryStore) KeyList(appID, userName, hostName string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key := range s.domains[memoryDomain{appID, userName, hostName}] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *MemoryStore) GetMulti(keys []string, appID, userName, hostName string) (map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	domain := s.domains[memoryDomain{appID, userName, hostName}]
+	out := map[string]interface{}{}
+	if keys == nil {
+		for key, v := range domain {
+			out[key] = deepCopy(v.Interface())
+		}
+		return out, nil
+	}
+	for _, key := range keys {
+		if v, ok := domain[key]; ok {
+			out[key] = deepCopy(v.Interface())
+		}
+	}
+	return out, nil
+}
+
+// GetValues returns the values of the domain sorted by key.
+func (s *MemoryStore) GetValues(appID, userName, hostName string) (Dict, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	domain := s.domains[memoryDomain{appID, userName, hostName}]
+	out := make(Dict, 0, len(domain))
+	for key, v := range domain {
+		out = append(out, DictEntry{key, copyValue(v)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+// ApplicationList returns the applications that have keys set for the user
+// and host, sorted.
+func (s *MemoryStore) ApplicationList(userName, hostName string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var apps []string
+	for d, values := range s.domains {
+		if d.userName == userName && d.hostName == hostName && len(values) > 0 {
+			apps = append(apps, d.appID)
+		}
+	}
+	sort.Strings(apps)
+	return apps, nil
+}
+
+// Writes returns the writes made so far, in order.
+func (s *MemoryStore) Writes() []MemoryWrite {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]MemoryWrite(nil), s.writes...)
+}
+
+// ResetWrites forgets the writes recorded so far, keeping the stored values.
+func (s *MemoryStore) ResetWrites() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes = nil
+}
+
+// SynchronizeCount returns how many times Synchronize was called for the
+// domain.
+func (s *MemoryStore) SynchronizeCount(appID, userName, hostName string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.syncs[memoryDomain{appID, userName, hostName}]
+}
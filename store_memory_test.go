@@ -0,0 +1,123 @@
+package cf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	var s Store = &MemoryStore{}
+
+	v, err := s.Get("a", "raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Nil(t, v)
+
+	require.NoError(t, s.Set("a", "aval", "raar", PreferencesCurrentUser, PreferencesAnyHost))
+	require.NoError(t, s.Set("b", []int{1, 2}, "raar", PreferencesCurrentUser, PreferencesAnyHost))
+	require.NoError(t, s.SetMulti(map[string]interface{}{"a": "aval2", "c": nil, "d": uint8(4)},
+		"raar", PreferencesCurrentUser, PreferencesAnyHost))
+	removed, err := s.Remove("b", "raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.True(t, removed)
+	require.NoError(t, s.Set("a", true, "raar", PreferencesCurrentUser, PreferencesCurrentHost))
+
+	v, err = s.Get("a", "raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, "aval2", v)
+	v, err = s.Get("d", "raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), v)
+	keys, err := s.KeyList("raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "d"}, keys)
+	keys, err = s.KeyList("other", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+	values, err := s.GetMulti(nil, "raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": "aval2", "d": int64(4)}, values)
+	values, err = s.GetMulti([]string{"d", "e"}, "raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"d": int64(4)}, values)
+	dict, err := s.GetValues("raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, Dict{{"a", String("aval2")}, {"d", Integer{4, true, 8}}}, dict)
+	apps, err := s.ApplicationList(PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, []string{"raar"}, apps)
+
+	require.IsType(t, &UnsupportedTypeError{}, s.Set("e", make(chan int), "raar", PreferencesCurrentUser, PreferencesAnyHost))
+
+	ok, err := s.Synchronize("raar", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	m := s.(*MemoryStore)
+	require.Equal(t, 1, m.SynchronizeCount("raar", PreferencesCurrentUser, PreferencesAnyHost))
+	require.Equal(t, 0, m.SynchronizeCount("raar", PreferencesCurrentUser, PreferencesCurrentHost))
+	require.Equal(t, []MemoryWrite{
+		{"a", "aval", "raar", PreferencesCurrentUser, PreferencesAnyHost},
+		{"b", []interface{}{int64(1), int64(2)}, "raar", PreferencesCurrentUser, PreferencesAnyHost},
+		{"a", "aval2", "raar", PreferencesCurrentUser, PreferencesAnyHost},
+		{"c", nil, "raar", PreferencesCurrentUser, PreferencesAnyHost},
+		{"d", int64(4), "raar", PreferencesCurrentUser, PreferencesAnyHost},
+		{"b", nil, "raar", PreferencesCurrentUser, PreferencesAnyHost},
+		{"a", true, "raar", PreferencesCurrentUser, PreferencesCurrentHost},
+	}, m.Writes())
+
+	m.ResetWrites()
+	require.Empty(t, m.Writes())
+}
+
+func TestMemoryStoreValues(t *testing.T) {
+	s := &MemoryStore{}
+	date := dateFromAbsoluteTime(6e8 + 0.0001234)
+	require.NoError(t, s.SetMulti(map[string]interface{}{"r": Real{48, 4}, "d": date, "i": 48},
+		"app", PreferencesCurrentUser, PreferencesAnyHost))
+
+	// Get converts to Go types, GetValues keeps the values as they were set
+	v, err := s.Get("r", "app", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, float32(48), v)
+	dict, err := s.GetValues("app", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, Dict{{"d", date}, {"i", Integer{48, true, 8}}, {"r", Real{48, 4}}}, dict)
+	dict, err = s.GetValues("other", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Empty(t, dict)
+}
+
+func TestMemoryStoreIsolation(t *testing.T) {
+	s := &MemoryStore{}
+	tree := map[string]interface{}{"list": []interface{}{"a"}}
+	require.NoError(t, s.Set("tree", tree, "app", PreferencesCurrentUser, PreferencesAnyHost))
+	tree["list"] = nil
+
+	v, err := s.Get("tree", "app", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	v.(map[string]interface{})["list"] = nil
+
+	v, err = s.Get("tree", "app", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"list": []interface{}{"a"}}, v)
+}
+
+func TestMemoryStoreRemove(t *testing.T) {
+	s := &MemoryStore{}
+	require.NoError(t, s.SetMulti(map[string]interface{}{"a": "aval", "b": "bval"}, "app", PreferencesCurrentUser, PreferencesAnyHost))
+
+	removed, err := s.Remove("missing", "app", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.False(t, removed)
+	removed, err = s.RemoveDomain("app", PreferencesCurrentUser, PreferencesAnyHost, true)
+	require.NoError(t, err)
+	require.True(t, removed)
+	removed, err = s.RemoveDomain("app", PreferencesCurrentUser, PreferencesAnyHost, true)
+	require.NoError(t, err)
+	require.False(t, removed)
+
+	keys, err := s.KeyList("app", PreferencesCurrentUser, PreferencesAnyHost)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
@@ -0,0 +1,208 @@
+package cf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TypedDomain reads the preferences of a domain as Go types. Every getter
+// returns its default when the key is not set, and a *PreferenceTypeError when
+// the key holds something that cannot be read as the type.
+//
+// Values are coerced the way the CFPreferencesGetApp*Value functions do, since
+// `defaults write` without a type flag writes strings: booleans may be stored as
+// numbers or as the strings "YES"/"NO", "true"/"false" and "1"/"0", and numbers
+// as decimal strings.
+type TypedDomain struct {
+	Domain Domain
+	// Store is the backend to read from, PreferencesStore if nil
+	Store Store
+}
+
+// Typed returns a TypedDomain reading d with the package-level Preferences
+// functions.
+func (d Domain) Typed() TypedDomain {
+	return TypedDomain{Domain: d}
+}
+
+// PreferenceTypeError is returned by the getters of TypedDomain for values of
+// the wrong type.
+type PreferenceTypeError struct {
+	Domain Domain
+	Key    string
+	Value  interface{}
+	// Type is the type that was asked for
+	Type string
+}
+
+func (e *PreferenceTypeError) Error() string {
+	return fmt.Sprintf("plist: %s in %s is %T, not %s", e.Key, e.Domain, e.Value, e.Type)
+}
+
+// get returns the value of key, or nil if it is not set
+func (t TypedDomain) get(key string) (Value, error) {
+	if err := t.Domain.Validate(); err != nil {
+		return nil, err
+	}
+	store := t.Store
+	if store == nil {
+		store = PreferencesStore{}
+	}
+	v, err := store.Get(key, t.Domain.Application, t.Domain.User, t.Domain.Host)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return ValueOf(v)
+}
+
+func (t TypedDomain) typeError(key string, v Value, typ string) error {
+	return &PreferenceTypeError{t.Domain, key, v.Interface(), typ}
+}
+
+// IsSet reports whether key is set in the domain.
+func (t TypedDomain) IsSet(key string) (bool, error) {
+	v, err := t.get(key)
+	return v != nil, err
+}
+
+// GetString reads a string.
+func (t TypedDomain) GetString(key string, def string) (string, error) {
+	v, err := t.get(key)
+	if err != nil || v == nil {
+		return def, err
+	}
+	if s, ok := v.(String); ok {
+		return string(s), nil
+	}
+	return def, t.typeError(key, v, "string")
+}
+
+// GetBool reads a boolean, also accepting numbers, which are true if not zero,
+// and the strings listed in the TypedDomain documentation.
+func (t TypedDomain) GetBool(key string, def bool) (bool, error) {
+	v, err := t.get(key)
+	if err != nil || v == nil {
+		return def, err
+	}
+	switch v := v.(type) {
+	case Bool:
+		return bool(v), nil
+	case Integer:
+		return v.Value != 0, nil
+	case Real:
+		return v.Value != 0, nil
+	case String:
+		switch strings.ToLower(string(v)) {
+		case "yes", "true", "1":
+			return true, nil
+		case "no", "false", "0":
+			return false, nil
+		}
+	}
+	return def, t.typeError(key, v, "bool")
+}
+
+// GetInt64 reads an integer, also accepting whole reals and decimal strings.
+func (t TypedDomain) GetInt64(key string, def int64) (int64, error) {
+	v, err := t.get(key)
+	if err != nil || v == nil {
+		return def, err
+	}
+	switch v := v.(type) {
+	case Integer:
+		if i, ok := v.Int64(); ok {
+			return i, nil
+		}
+	case Real:
+		if v.Value == math.Trunc(v.Value) && v.Value >= math.MinInt64 && v.Value < math.MaxInt64 {
+			return int64(v.Value), nil
+		}
+	case String:
+		if i, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64); err == nil {
+			return i, nil
+		}
+	}
+	return def, t.typeError(key, v, "int64")
+}
+
+// GetFloat64 reads a number, also accepting decimal strings.
+func (t TypedDomain) GetFloat64(key string, def float64) (float64, error) {
+	v, err := t.get(key)
+	if err != nil || v == nil {
+		return def, err
+	}
+	switch v := v.(type) {
+	case Real:
+		return v.Value, nil
+	case Integer:
+		if i, ok := v.Int64(); ok {
+			return float64(i), nil
+		}
+		return float64(v.Value), nil
+	case String:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(string(v)), 64); err == nil {
+			return f, nil
+		}
+	}
+	return def, t.typeError(key, v, "float64")
+}
+
+// GetDate reads a date.
+func (t TypedDomain) GetDate(key string, def time.Time) (time.Time, error) {
+	v, err := t.get(key)
+	if err != nil || v == nil {
+		return def, err
+	}
+	if d, ok := v.(Date); ok {
+		return d.Interface().(time.Time), nil
+	}
+	return def, t.typeError(key, v, "date")
+}
+
+// GetData reads a data value.
+func (t TypedDomain) GetData(key string, def []byte) ([]byte, error) {
+	v, err := t.get(key)
+	if err != nil || v == nil {
+		return def, err
+	}
+	if d, ok := v.(Data); ok {
+		return []byte(d), nil
+	}
+	return def, t.typeError(key, v, "data")
+}
+
+// GetStringSlice reads an array of strings.
+func (t TypedDomain) GetStringSlice(key string, def []string) ([]string, error) {
+	v, err := t.get(key)
+	if err != nil || v == nil {
+		return def, err
+	}
+	a, ok := v.(Array)
+	if !ok {
+		return def, t.typeError(key, v, "array of strings")
+	}
+	out := make([]string, len(a))
+	for i, elem := range a {
+		s, ok := elem.(String)
+		if !ok {
+			return def, t.typeError(key, v, "array of strings")
+		}
+		out[i] = string(s)
+	}
+	return out, nil
+}
+
+// GetDict reads a dictionary as Goize returns it.
+func (t TypedDomain) GetDict(key string, def map[string]interface{}) (map[string]interface{}, error) {
+	v, err := t.get(key)
+	if err != nil || v == nil {
+		return def, err
+	}
+	if d, ok := v.(Dict); ok {
+		return d.Interface().(map[string]interface{}), nil
+	}
+	return def, t.typeError(key, v, "dictionary")
+}
@@ -0,0 +1,104 @@
+package cf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedDomain(t *testing.T) {
+	store := &MemoryStore{}
+	d := TypedDomain{UserDomain("com.example"), store}
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, store.SetMulti(map[string]interface{}{
+		"string":  "s",
+		"yes":     "YES",
+		"no":      "no",
+		"one":     "1",
+		"int":     int64(42),
+		"intstr":  " -7",
+		"real":    2.5,
+		"float":   float32(3),
+		"bool":    true,
+		"date":    date,
+		"data":    []byte{1, 2},
+		"strings": []string{"a", "b"},
+		"mixed":   []interface{}{"a", int64(1)},
+		"dict":    map[string]interface{}{"a": "b"},
+	}, "com.example", PreferencesCurrentUser, PreferencesAnyHost))
+
+	set, err := d.IsSet("string")
+	require.NoError(t, err)
+	require.True(t, set)
+	set, err = d.IsSet("missing")
+	require.NoError(t, err)
+	require.False(t, set)
+
+	s, err := d.GetString("string", "def")
+	require.NoError(t, err)
+	require.Equal(t, "s", s)
+	s, err = d.GetString("missing", "def")
+	require.NoError(t, err)
+	require.Equal(t, "def", s)
+	s, err = d.GetString("int", "def")
+	require.IsType(t, &PreferenceTypeError{}, err)
+	require.Equal(t, "def", s)
+	require.Equal(t, "plist: int in {com.example kCFPreferencesCurrentUser kCFPreferencesAnyHost} is int64, not string", err.Error())
+
+	for key, expected := range map[string]bool{"yes": true, "no": false, "one": true, "int": true, "bool": true, "missing": true} {
+		b, err := d.GetBool(key, true)
+		require.NoError(t, err, key)
+		require.Equal(t, expected, b, key)
+	}
+	_, err = d.GetBool("string", false)
+	require.IsType(t, &PreferenceTypeError{}, err)
+
+	for key, expected := range map[string]int64{"int": 42, "intstr": -7, "float": 3, "one": 1, "missing": 9} {
+		i, err := d.GetInt64(key, 9)
+		require.NoError(t, err, key)
+		require.Equal(t, expected, i, key)
+	}
+	for _, key := range []string{"real", "bool", "string"} {
+		_, err = d.GetInt64(key, 0)
+		require.IsType(t, &PreferenceTypeError{}, err, key)
+	}
+
+	for key, expected := range map[string]float64{"real": 2.5, "float": 3, "int": 42, "intstr": -7} {
+		f, err := d.GetFloat64(key, 0)
+		require.NoError(t, err, key)
+		require.Equal(t, expected, f, key)
+	}
+	_, err = d.GetFloat64("date", 0)
+	require.IsType(t, &PreferenceTypeError{}, err)
+
+	tm, err := d.GetDate("date", time.Time{})
+	require.NoError(t, err)
+	require.True(t, date.Equal(tm))
+	_, err = d.GetDate("string", time.Time{})
+	require.IsType(t, &PreferenceTypeError{}, err)
+
+	data, err := d.GetData("data", nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2}, data)
+	_, err = d.GetData("string", nil)
+	require.IsType(t, &PreferenceTypeError{}, err)
+
+	ss, err := d.GetStringSlice("strings", nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, ss)
+	ss, err = d.GetStringSlice("missing", []string{"x"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"x"}, ss)
+	_, err = d.GetStringSlice("mixed", nil)
+	require.IsType(t, &PreferenceTypeError{}, err)
+
+	m, err := d.GetDict("dict", nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": "b"}, m)
+	_, err = d.GetDict("strings", nil)
+	require.IsType(t, &PreferenceTypeError{}, err)
+
+	_, err = TypedDomain{Domain: Domain{}, Store: store}.GetString("string", "")
+	require.IsType(t, &InvalidDomainError{}, err)
+}
@@ -1,3 +1,6 @@
+//go:build darwin
+// +build darwin
+
 package cf
 
 // Taken from go-osx-plist (see LICENSE), heavily adapted
@@ -5,14 +8,38 @@ package cf
 // #import <CoreFoundation/CoreFoundation.h>
 // #import <ApplicationServices/ApplicationServices.h> // CGFloat
 // #cgo LDFLAGS: -framework CoreFoundation
+//
+// typedef const struct __CFKeyedArchiverUID *CFKeyedArchiverUIDRef;
+// CFTypeID _CFKeyedArchiverUIDGetTypeID(void);
+// uint32_t _CFKeyedArchiverUIDGetValue(CFKeyedArchiverUIDRef uid);
+//
+// #define gocf_kCFNumberSInt128Type 17
+// typedef struct {
+//     int64_t high;
+//     uint64_t low;
+// } gocf_CFSInt128Struct;
 import "C"
 import (
+	"math"
+	"math/big"
+	"strconv"
 	"time"
 	"unsafe"
 )
 
 type typeRef C.CFTypeRef
 
+type UnknownCFTypeError struct {
+	CFTypeID C.CFTypeID
+}
+
+func (e *UnknownCFTypeError) Error() string {
+	cfStr := C.CFCopyTypeIDDescription(e.CFTypeID)
+	str := stringRef(cfStr).Goize()
+	Release(cfStr)
+	return "plist: unknown CFTypeID " + strconv.Itoa(int(e.CFTypeID)) + " (" + str + ")"
+}
+
 func Release(i interface{}) {
 	o := i.(typeRef)
 	if o != 0 {
@@ -29,7 +56,7 @@ func (t typeRef) Goize() (interface{}, error) {
 	case C.CFStringGetTypeID():
 		return stringRef(t).Goize(), nil
 	case C.CFNumberGetTypeID():
-		return numberRef(t).Goize(), nil
+		return numberRef(t).Goize()
 	case C.CFBooleanGetTypeID():
 		return boolRef(t).Goize(), nil
 	case C.CFDataGetTypeID():
@@ -40,10 +67,50 @@ func (t typeRef) Goize() (interface{}, error) {
 		return arrayRef(t).Goize()
 	case C.CFDictionaryGetTypeID():
 		return dictionaryRef(t).Goize()
+	case C._CFKeyedArchiverUIDGetTypeID():
+		return uidRef(t).Value(), nil
+	}
+	return nil, &UnknownCFTypeError{typeId}
+}
+
+// Value converts the CF object into a Value, keeping number types and full
+// date precision. Dictionary keys come in CFDictionary's own order.
+func (t typeRef) Value() (Value, error) {
+	if t == 0 {
+		return nil, nil
+	}
+	typeId := C.CFGetTypeID(C.CFTypeRef(t))
+	switch typeId {
+	case C.CFStringGetTypeID():
+		return String(stringRef(t).Goize()), nil
+	case C.CFNumberGetTypeID():
+		return numberRef(t).Value()
+	case C.CFBooleanGetTypeID():
+		return Bool(boolRef(t).Goize()), nil
+	case C.CFDataGetTypeID():
+		return Data(dataRef(t).Goize()), nil
+	case C.CFDateGetTypeID():
+		return dateFromAbsoluteTime(float64(C.CFDateGetAbsoluteTime(C.CFDateRef(t)))), nil
+	case C.CFArrayGetTypeID():
+		return arrayRef(t).Value()
+	case C.CFDictionaryGetTypeID():
+		return dictionaryRef(t).Value()
+	case C._CFKeyedArchiverUIDGetTypeID():
+		return uidRef(t).Value(), nil
 	}
 	return nil, &UnknownCFTypeError{typeId}
 }
 
+// Unmarshal converts the CF object into the Go value pointed to by dst, see
+// the package-level Unmarshal
+func (t typeRef) Unmarshal(dst interface{}) error {
+	v, err := t.Goize()
+	if err != nil {
+		return err
+	}
+	return Unmarshal(v, dst)
+}
+
 type stringRef C.CFStringRef
 
 func (s stringRef) Goize() string {
@@ -72,94 +139,172 @@ func (n numberRef) GoizeFloat64() float64 {
 	return float64(v)
 }
 
-func (n numberRef) GoizeInt64() int64 {
-	var v C.SInt64
-	C.CFNumberGetValue(C.CFNumberRef(n), C.kCFNumberSInt64Type, unsafe.Pointer(&v))
-	return int64(v)
+// integer returns the value of the number, with floating point numbers
+// truncated towards zero. CFNumber converts every number to
+// kCFNumberSInt128Type without loss, so the result is only out of range if it
+// needs more than 64 bits.
+func (n numberRef) integer() (Integer, error) {
+	var v C.gocf_CFSInt128Struct
+	C.CFNumberGetValue(C.CFNumberRef(n), C.gocf_kCFNumberSInt128Type, unsafe.Pointer(&v))
+	high, low := int64(v.high), uint64(v.low)
+	switch {
+	case high == 0 && low > math.MaxInt64:
+		return Uint(low), nil
+	case high == 0 || high == -1 && low > math.MaxInt64:
+		return Int(int64(low)), nil
+	}
+	i := new(big.Int).Lsh(big.NewInt(high), 64)
+	i.Or(i, new(big.Int).SetUint64(low))
+	return Integer{}, &OverflowError{i.String(), "uint64"}
 }
 
-func (n numberRef) GoizeUint32() uint32 {
-	var v C.SInt64
-	C.CFNumberGetValue(C.CFNumberRef(n), C.kCFNumberSInt64Type, unsafe.Pointer(&v))
-	return uint32(v)
+func (n numberRef) GoizeInt64() (int64, error) {
+	i, err := n.integer()
+	if err != nil {
+		return 0, err
+	}
+	v, ok := i.Int64()
+	if !ok {
+		return 0, &OverflowError{formatInteger(i), "int64"}
+	}
+	return v, nil
 }
 
-func (n numberRef) Goize() interface{} {
+func (n numberRef) GoizeUint32() (uint32, error) {
+	v, err := n.GoizeUint64()
+	if err != nil {
+		return 0, err
+	}
+	if v > math.MaxUint32 {
+		return 0, &OverflowError{strconv.FormatUint(v, 10), "uint32"}
+	}
+	return uint32(v), nil
+}
+
+func (n numberRef) GoizeUint64() (uint64, error) {
+	i, err := n.integer()
+	if err != nil {
+		return 0, err
+	}
+	v, ok := i.Uint64()
+	if !ok {
+		return 0, &OverflowError{formatInteger(i), "uint64"}
+	}
+	return v, nil
+}
+
+// Goize returns the number as the Go type matching its CFNumberType. Numbers
+// of kCFNumberSInt128Type become int64 if they fit, and uint64 otherwise.
+func (n numberRef) Goize() (interface{}, error) {
 	cfn := C.CFNumberRef(n)
 	typ := C.CFNumberGetType(cfn)
 	switch typ {
 	case C.kCFNumberSInt8Type:
 		var sint C.SInt8
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&sint))
-		return int8(sint)
+		return int8(sint), nil
 	case C.kCFNumberSInt16Type:
 		var sint C.SInt16
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&sint))
-		return int16(sint)
+		return int16(sint), nil
 	case C.kCFNumberSInt32Type:
 		var sint C.SInt32
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&sint))
-		return int32(sint)
+		return int32(sint), nil
 	case C.kCFNumberSInt64Type:
-		return n.GoizeInt64()
+		var sint C.SInt64
+		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&sint))
+		return int64(sint), nil
 	case C.kCFNumberFloat32Type:
 		var float C.Float32
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&float))
-		return float32(float)
+		return float32(float), nil
 	case C.kCFNumberFloat64Type:
-		return n.GoizeFloat64()
+		return n.GoizeFloat64(), nil
 	case C.kCFNumberCharType:
 		var char C.char
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&char))
-		return byte(char)
+		return byte(char), nil
 	case C.kCFNumberShortType:
 		var short C.short
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&short))
-		return int16(short)
+		return int16(short), nil
 	case C.kCFNumberIntType:
 		var i C.int
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&i))
-		return int32(i)
+		return int32(i), nil
 	case C.kCFNumberLongType:
 		var long C.long
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&long))
-		return int(long)
+		return int(long), nil
 	case C.kCFNumberLongLongType:
 		// this is the only type that may actually overflow us
 		var longlong C.longlong
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&longlong))
-		return int64(longlong)
+		return int64(longlong), nil
 	case C.kCFNumberFloatType:
 		var float C.float
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&float))
-		return float32(float)
+		return float32(float), nil
 	case C.kCFNumberDoubleType:
 		var double C.double
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&double))
-		return float64(double)
+		return float64(double), nil
 	case C.kCFNumberCFIndexType:
 		// CFIndex is a long
 		var index C.CFIndex
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&index))
-		return int(index)
+		return int(index), nil
 	case C.kCFNumberNSIntegerType:
 		// We don't have a definition of NSInteger, but we know it's either an int or a long
 		var nsInt C.long
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&nsInt))
-		return int(nsInt)
+		return int(nsInt), nil
+	case C.gocf_kCFNumberSInt128Type:
+		i, err := n.integer()
+		if err != nil {
+			return nil, err
+		}
+		return i.Interface(), nil
 	case C.kCFNumberCGFloatType:
 		// CGFloat is a float or double
 		var float C.CGFloat
 		C.CFNumberGetValue(cfn, typ, unsafe.Pointer(&float))
 		if unsafe.Sizeof(float) == 8 {
-			return float64(float)
+			return float64(float), nil
 		} else {
-			return float32(float)
+			return float32(float), nil
 		}
 	}
 	panic("plist: unknown CFNumber type")
 }
 
+// Value converts the number into an Integer or a Real of the same width. The
+// C types kCFNumberCharType, kCFNumberLongType and the like are mapped to the
+// integer of their size; kCFNumberCharType is unsigned, as in Goize.
+// kCFNumberSInt128Type numbers are 16 bytes wide only if they do not fit in
+// an int64.
+func (n numberRef) Value() (Value, error) {
+	cfn := C.CFNumberRef(n)
+	size := int(C.CFNumberGetByteSize(cfn))
+	if C.CFNumberIsFloatType(cfn) != 0 {
+		return Real{n.GoizeFloat64(), size}, nil
+	}
+	if C.CFNumberGetType(cfn) == C.kCFNumberCharType {
+		var char C.char
+		C.CFNumberGetValue(cfn, C.kCFNumberCharType, unsafe.Pointer(&char))
+		return Integer{Value: uint64(byte(char)), Width: 1}, nil
+	}
+	i, err := n.integer()
+	if err != nil {
+		return nil, err
+	}
+	if size < 16 {
+		i.Width = size
+	}
+	return i, nil
+}
+
 type dataRef C.CFDataRef
 
 func (d dataRef) Goize() []byte {
@@ -200,6 +345,24 @@ func (a arrayRef) Goize() ([]interface{}, error) {
 	return out, nil
 }
 
+func (a arrayRef) Value() (Array, error) {
+	count := C.CFArrayGetCount(C.CFArrayRef(a))
+	out := make(Array, int(count))
+	if count == 0 {
+		return out, nil
+	}
+	values := make([]C.CFTypeRef, int(count))
+	C.CFArrayGetValues(C.CFArrayRef(a), C.CFRange{0, count}, (*unsafe.Pointer)(unsafe.Pointer(&values[0])))
+	for i, value := range values {
+		v, err := typeRef(value).Value()
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
 type dictionaryRef C.CFDictionaryRef
 
 func (d dictionaryRef) Goize() (map[string]interface{}, error) {
@@ -227,3 +390,34 @@ func (d dictionaryRef) Goize() (map[string]interface{}, error) {
 	}
 	return out, nil
 }
+
+func (d dictionaryRef) Value() (Dict, error) {
+	count := int(C.CFDictionaryGetCount(C.CFDictionaryRef(d)))
+	out := make(Dict, 0, count)
+	if count == 0 {
+		return out, nil
+	}
+	stringTypeID := C.CFStringGetTypeID()
+	keys := make([]C.CFTypeRef, count)
+	values := make([]C.CFTypeRef, count)
+	C.CFDictionaryGetKeysAndValues(C.CFDictionaryRef(d), (*unsafe.Pointer)(unsafe.Pointer(&keys[0])),
+		(*unsafe.Pointer)(unsafe.Pointer(&values[0])))
+	for i := 0; i < count; i++ {
+		t := C.CFGetTypeID(keys[i])
+		if t != stringTypeID {
+			return nil, &UnsupportedKeyTypeError{int(t)}
+		}
+		val, err := typeRef(values[i]).Value()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, DictEntry{stringRef(keys[i]).Goize(), val})
+	}
+	return out, nil
+}
+
+type uidRef C.CFKeyedArchiverUIDRef
+
+func (u uidRef) Value() UID {
+	return UID(C._CFKeyedArchiverUIDGetValue(C.CFKeyedArchiverUIDRef(u)))
+}
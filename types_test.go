@@ -1,8 +1,12 @@
+//go:build darwin
+// +build darwin
+
 package cf
 
 // Taken from go-osx-plist (see LICENSE), heavily adapted
 
 import (
+	"math"
 	"reflect"
 	"testing"
 	"testing/quick"
@@ -44,8 +48,8 @@ func TestCFString(t *testing.T) {
 }
 
 func TestCFNumber_Int64(t *testing.T) {
-	f := func(i int64) int64 { return i }
-	g := func(i int64) int64 {
+	f := func(i int64) (int64, error) { return i, nil }
+	g := func(i int64) (int64, error) {
 		pool := &Pool{}
 		defer pool.Release()
 
@@ -58,8 +62,8 @@ func TestCFNumber_Int64(t *testing.T) {
 }
 
 func TestCFNumber_UInt32(t *testing.T) {
-	f := func(i uint32) uint32 { return i }
-	g := func(i uint32) uint32 {
+	f := func(i uint32) (uint32, error) { return i, nil }
+	g := func(i uint32) (uint32, error) {
 		pool := &Pool{}
 		defer pool.Release()
 
@@ -71,6 +75,48 @@ func TestCFNumber_UInt32(t *testing.T) {
 	}
 }
 
+func TestCFNumber_UInt64(t *testing.T) {
+	f := func(i uint64) (uint64, error) { return i, nil }
+	g := func(i uint64) (uint64, error) {
+		pool := &Pool{}
+		defer pool.Release()
+
+		cfNum := pool.Uint64(i)
+		return cfNum.GoizeUint64()
+	}
+	if err := quick.CheckEqual(f, g, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCFNumber_Overflow(t *testing.T) {
+	pool := &Pool{}
+	defer pool.Release()
+
+	big := pool.Uint64(math.MaxUint64)
+	_, err := big.GoizeInt64()
+	require.IsType(t, &OverflowError{}, err)
+	_, err = big.GoizeUint32()
+	require.IsType(t, &OverflowError{}, err)
+	_, err = pool.Int64(-1).GoizeUint64()
+	require.IsType(t, &OverflowError{}, err)
+	_, err = pool.Int64(math.MaxUint32 + 1).GoizeUint32()
+	require.IsType(t, &OverflowError{}, err)
+
+	val, err := typeRef(big).Goize()
+	require.NoError(t, err)
+	require.Equal(t, uint64(math.MaxUint64), val)
+	val, err = typeRef(pool.Uint64(math.MaxInt64)).Goize()
+	require.NoError(t, err)
+	require.Equal(t, int64(math.MaxInt64), val)
+
+	obj, err := pool.Object(map[string]interface{}{"serial": uint64(1<<63 + 5), "count": uint(7)})
+	require.NoError(t, err)
+	val, err = obj.Goize()
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"serial": uint64(1<<63 + 5), "count": int64(7)}, val)
+}
+
 func TestCFNumber_Float64(t *testing.T) {
 	f := func(f float64) float64 { return f }
 	g := func(f float64) float64 {
@@ -150,3 +196,68 @@ func TestArbitrary(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestStruct(t *testing.T) {
+	type fileData struct {
+		URL string `plist:"_CFURLString"`
+	}
+	type tile struct {
+		Label    string    `plist:"file-label"`
+		FileData *fileData `plist:"file-data,omitempty"`
+		Size     float32   `plist:",omitempty"`
+	}
+
+	p := &Pool{}
+	defer p.Release()
+
+	in := tile{Label: "Safari", FileData: &fileData{URL: "file:///Applications/Safari.app/"}}
+	cfObj, err := p.Object(in)
+	require.NoError(t, err)
+	val, err := cfObj.Goize()
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"file-label": "Safari",
+		"file-data":  map[string]interface{}{"_CFURLString": "file:///Applications/Safari.app/"},
+	}, val)
+
+	var out tile
+	require.NoError(t, cfObj.Unmarshal(&out))
+	require.Equal(t, in, out)
+}
+
+func TestValue(t *testing.T) {
+	p := &Pool{}
+	defer p.Release()
+
+	in := Dict{
+		{"int8", Integer{Value: 0x7f, Signed: true, Width: 1}},
+		{"int32", Integer{Value: 1 << 20, Signed: true, Width: 4}},
+		{"float32", Real{0.5, 4}},
+		{"float64", Real{0.1, 8}},
+		{"date", Date{time.Date(2020, 1, 2, 3, 4, 5, 250000000, time.UTC)}},
+		{"uid", UID(3)},
+		{"array", Array{String("a"), Bool(false), Data{1}}},
+	}
+	cfObj, err := p.Value(in)
+	require.NoError(t, err)
+	out, err := cfObj.Value()
+	require.NoError(t, err)
+
+	// CFDictionary does not keep key order
+	require.Len(t, out, len(in))
+	for _, e := range in {
+		v, ok := out.(Dict).Get(e.Key)
+		require.True(t, ok, e.Key)
+		if d, ok := e.Value.(Date); ok {
+			require.True(t, d.Equal(v.(Date).Time))
+			continue
+		}
+		require.Equal(t, e.Value, v, e.Key)
+	}
+
+	cfObj, err = p.Value(Uint(1 << 63))
+	require.NoError(t, err)
+	out, err = cfObj.Value()
+	require.NoError(t, err)
+	require.Equal(t, Uint(1<<63), out)
+}
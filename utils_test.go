@@ -47,7 +47,6 @@ func (a Arbitrary) Generate(rand *rand.Rand, size int) reflect.Value {
 				key, ok := generateString(rand)
 				if !ok {
 					panic("Couldn't generate string")
-					return reflect.Value{}
 				}
 				value := azero.Generate(rand, size).Interface().(Arbitrary).Value
 				m[key] = value
@@ -72,7 +71,6 @@ func (a Arbitrary) Generate(rand *rand.Rand, size int) reflect.Value {
 				return reflect.ValueOf(Arbitrary{Value: time.Unix(0, nano)})
 			}
 			panic("Couldn't generate date")
-			return reflect.Value{}
 		case 3: // Number
 			switch rand.Intn(3) {
 			case 0: // int64
@@ -89,14 +87,12 @@ func (a Arbitrary) Generate(rand *rand.Rand, size int) reflect.Value {
 			}
 			// conversion failed
 			panic("Couldn't generate string")
-			return reflect.Value{}
 		}
 		if val, ok := quick.Value(typ, rand); ok {
 			return reflect.ValueOf(Arbitrary{Value: val.Interface()})
 		}
 	}
 	panic("Can't generate value")
-	return reflect.Value{}
 }
 
 // standardize converts any integer values that fit within an int64 into an int64.
@@ -0,0 +1,431 @@
+package cf
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf16"
+)
+
+// Value is a property list value that keeps everything the plain Go
+// representation returned by typeRef.Goize loses: the width and signedness of
+// numbers, full date precision, dictionary key order and keyed archiver UIDs.
+//
+// It is implemented by String, Integer, Real, Bool, Date, Data, Array, Dict and
+// UID. typeRef.Value, Pool.Value and the Decode*PlistValue functions produce
+// and consume Values; the encoders accept Values anywhere in their input.
+type Value interface {
+	// Interface converts the value into the plain Go types the package's
+	// decoders return: string, int64 and other integer types, float32, float64,
+	// bool, []byte, time.Time, []interface{} (nil when empty),
+	// map[string]interface{} and UID.
+	Interface() interface{}
+
+	isValue()
+}
+
+// String is a property list string.
+type String string
+
+// Integer is a property list integer.
+type Integer struct {
+	// Value holds the bits of the integer: an int64 in two's complement if
+	// Signed is set, an uint64 otherwise
+	Value  uint64
+	Signed bool
+	// Width is the size of the integer in bytes: 1, 2, 4, 8 or 16 (CFNumber's
+	// kCFNumberSInt128Type, only used for values outside of the int64 range)
+	Width int
+}
+
+// Real is a property list floating point number.
+type Real struct {
+	Value float64
+	// Width is 4 for single and 8 for double precision
+	Width int
+}
+
+// Bool is a property list boolean.
+type Bool bool
+
+// Date is a property list date.
+type Date struct {
+	time.Time
+}
+
+// Data is a property list byte string.
+type Data []byte
+
+// Array is a property list array.
+type Array []Value
+
+// Dict is a property list dictionary. It keeps its keys in order.
+type Dict []DictEntry
+
+// DictEntry is a key and its value in a Dict.
+type DictEntry struct {
+	Key   string
+	Value Value
+}
+
+// UID is a reference to an object in an NSKeyedArchiver archive
+// (CFKeyedArchiverUID).
+type UID uint64
+
+func (String) isValue()  {}
+func (Integer) isValue() {}
+func (Real) isValue()    {}
+func (Bool) isValue()    {}
+func (Date) isValue()    {}
+func (Data) isValue()    {}
+func (Array) isValue()   {}
+func (Dict) isValue()    {}
+func (UID) isValue()     {}
+
+// Int returns a signed 64-bit Integer.
+func Int(i int64) Integer {
+	return Integer{Value: uint64(i), Signed: true, Width: 8}
+}
+
+// Uint returns an unsigned Integer, 16 bytes wide if u does not fit in an int64.
+func Uint(u uint64) Integer {
+	if u > math.MaxInt64 {
+		return Integer{Value: u, Width: 16}
+	}
+	return Integer{Value: u, Width: 8}
+}
+
+// Int64 returns the integer as an int64, or false if it does not fit.
+func (i Integer) Int64() (int64, bool) {
+	if !i.Signed && i.Value > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(i.Value), true
+}
+
+// Uint64 returns the integer as an uint64, or false if it is negative.
+func (i Integer) Uint64() (uint64, bool) {
+	if i.Signed && int64(i.Value) < 0 {
+		return 0, false
+	}
+	return i.Value, true
+}
+
+// cfWidth returns the width of the CFNumber type that holds the integer: Width,
+// or 8 if it is not set, doubled for unsigned integers narrower than 8 bytes
+// as CFNumber has no unsigned types. It returns an *OverflowError if Value
+// does not fit in Width.
+func (i Integer) cfWidth() (int, error) {
+	width := i.Width
+	if width == 0 {
+		width = 8
+	}
+	if width >= 8 {
+		return width, nil
+	}
+	bits := uint(8 * width)
+	if i.Signed {
+		if v := int64(i.Value); v < -1<<(bits-1) || v >= 1<<(bits-1) {
+			return 0, &OverflowError{formatInteger(i), "int" + strconv.Itoa(int(bits))}
+		}
+		return width, nil
+	}
+	if i.Value >= 1<<bits {
+		return 0, &OverflowError{formatInteger(i), "uint" + strconv.Itoa(int(bits))}
+	}
+	return width * 2, nil
+}
+
+// Get returns the value for key.
+func (d Dict) Get(key string) (Value, bool) {
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set replaces the value for key, or appends key if it is not present.
+func (d *Dict) Set(key string, v Value) {
+	for i, e := range *d {
+		if e.Key == key {
+			(*d)[i].Value = v
+			return
+		}
+	}
+	*d = append(*d, DictEntry{key, v})
+}
+
+// Delete removes key and reports whether it was present.
+func (d *Dict) Delete(key string) bool {
+	for i, e := range *d {
+		if e.Key == key {
+			*d = append((*d)[:i], (*d)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Keys returns the keys in order.
+func (d Dict) Keys() []string {
+	keys := make([]string, len(d))
+	for i, e := range d {
+		keys[i] = e.Key
+	}
+	return keys
+}
+
+func (s String) Interface() interface{} {
+	return string(s)
+}
+
+func (i Integer) Interface() interface{} {
+	if i.Signed {
+		switch i.Width {
+		case 1:
+			return int8(i.Value)
+		case 2:
+			return int16(i.Value)
+		case 4:
+			return int32(i.Value)
+		}
+		return int64(i.Value)
+	}
+	switch i.Width {
+	case 1:
+		return uint8(i.Value)
+	case 2:
+		return uint16(i.Value)
+	case 4:
+		return uint32(i.Value)
+	case 16:
+		if i.Value <= math.MaxInt64 {
+			return int64(i.Value)
+		}
+	}
+	return i.Value
+}
+
+func (r Real) Interface() interface{} {
+	if r.Width == 4 {
+		return float32(r.Value)
+	}
+	return r.Value
+}
+
+func (b Bool) Interface() interface{} {
+	return bool(b)
+}
+
+// Interface returns the date rounded to milliseconds in the local time zone,
+// as dateRef.Goize does.
+func (d Date) Interface() interface{} {
+	return goizeAbsoluteTime(d.absoluteTime())
+}
+
+func (d Data) Interface() interface{} {
+	return []byte(d)
+}
+
+func (a Array) Interface() interface{} {
+	if len(a) == 0 {
+		// arrayRef.Goize returns nil for empty arrays
+		return []interface{}(nil)
+	}
+	out := make([]interface{}, len(a))
+	for i, v := range a {
+		out[i] = v.Interface()
+	}
+	return out
+}
+
+func (d Dict) Interface() interface{} {
+	out := make(map[string]interface{}, len(d))
+	for _, e := range d {
+		out[e.Key] = e.Value.Interface()
+	}
+	return out
+}
+
+func (u UID) Interface() interface{} {
+	return u
+}
+
+// copyValue returns a copy of v that shares no slices with it
+func copyValue(v Value) Value {
+	switch v := v.(type) {
+	case Data:
+		return append(Data{}, v...)
+	case Array:
+		out := make(Array, len(v))
+		for i, elem := range v {
+			out[i] = copyValue(elem)
+		}
+		return out
+	case Dict:
+		out := make(Dict, len(v))
+		for i, e := range v {
+			out[i] = DictEntry{e.Key, copyValue(e.Value)}
+		}
+		return out
+	}
+	return v
+}
+
+// dateFromAbsoluteTime converts CFAbsoluteTime to a Date, to the nanosecond
+func dateFromAbsoluteTime(abs float64) Date {
+	sec := math.Floor(abs)
+	nsec := math.Round((abs - sec) * float64(time.Second))
+	return Date{time.Unix(int64(sec)+absoluteTimeIntervalSince1970, int64(nsec))}
+}
+
+// absoluteTime converts the date to CFAbsoluteTime. Whole seconds are rebased
+// before adding the fraction so that the result is exact wherever a double
+// allows it.
+func (d Date) absoluteTime() float64 {
+	return float64(d.Unix()-absoluteTimeIntervalSince1970) + float64(d.Nanosecond())/float64(time.Second)
+}
+
+// truncateDate truncates t to milliseconds, as Pool.Date does
+func truncateDate(t time.Time) time.Time {
+	ms := int64(time.Duration(t.UnixNano()) / time.Millisecond * time.Millisecond)
+	return time.Unix(0, ms)
+}
+
+// ValueOf converts a Go value into a Value without losing information: integer
+// and float widths are kept and dates are not truncated. It accepts everything
+// Pool.Object accepts, and passes Values through unchanged.
+func ValueOf(i interface{}) (Value, error) {
+	return valueOf(reflect.ValueOf(i), false)
+}
+
+// objectValue converts a Go value into the Value Pool.Object would build a CF
+// object for: all integers become signed 64-bit ones, unless they only fit in
+// 128 bits, and dates are truncated to milliseconds. The file encoders use it
+// so that they accept exactly the same inputs as Pool.Object and produce the
+// same data.
+func objectValue(i interface{}) (Value, error) {
+	return valueOf(reflect.ValueOf(i), true)
+}
+
+var valueType = reflect.TypeOf((*Value)(nil)).Elem()
+
+func valueOf(v reflect.Value, asObject bool) (Value, error) {
+	if !v.IsValid() {
+		return nil, &UnsupportedValueError{v, "nil value"}
+	}
+	if v.Type().Implements(valueType) && (v.Kind() != reflect.Interface && v.Kind() != reflect.Ptr || !v.IsNil()) {
+		return v.Interface().(Value), nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return Bool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if asObject {
+			return Int(v.Int()), nil
+		}
+		return Integer{Value: uint64(v.Int()), Signed: true, Width: int(v.Type().Size())}, nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uintptr, reflect.Uint64:
+		if !asObject {
+			return Integer{Value: v.Uint(), Width: int(v.Type().Size())}, nil
+		}
+		// Pool.Uint64 only goes 128-bit for values outside of the int64 range
+		if u := v.Uint(); u > math.MaxInt64 {
+			return Uint(u), nil
+		}
+		return Int(int64(v.Uint())), nil
+	case reflect.Float32:
+		return Real{v.Float(), 4}, nil
+	case reflect.Float64:
+		return Real{v.Float(), 8}, nil
+	case reflect.String:
+		return String(v.String()), nil
+	case reflect.Struct:
+		if v.Type() == timeType {
+			t := v.Interface().(time.Time)
+			if asObject {
+				t = truncateDate(t)
+			}
+			return Date{t}, nil
+		}
+		keys, values := structEntries(v)
+		out := make(Dict, len(keys))
+		for i, key := range keys {
+			elem, err := valueOf(values[i], asObject)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = DictEntry{key, elem}
+		}
+		return out, nil
+	case reflect.Array, reflect.Slice:
+		// check for []byte first (byte is uint8)
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := make(Data, v.Len())
+			reflect.Copy(reflect.ValueOf([]byte(data)), v)
+			return data, nil
+		}
+		out := make(Array, v.Len())
+		for i := range out {
+			elem, err := valueOf(v.Index(i), asObject)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = elem
+		}
+		return out, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, &UnsupportedTypeError{v.Type()}
+		}
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sortPlistKeys(keys)
+		out := make(Dict, len(keys))
+		for i, key := range keys {
+			elem, err := valueOf(v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())), asObject)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = DictEntry{key, elem}
+		}
+		return out, nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, &UnsupportedValueError{v, "nil interface"}
+		}
+		return valueOf(v.Elem(), asObject)
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, &UnsupportedValueError{v, "nil pointer"}
+		}
+		return valueOf(v.Elem(), asObject)
+	}
+	return nil, &UnsupportedTypeError{v.Type()}
+}
+
+// sortPlistKeys orders keys by UTF-16 code units, as CFStringCompare does
+func sortPlistKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		return compareUTF16(keys[i], keys[j]) < 0
+	})
+}
+
+func compareUTF16(a, b string) int {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			if ua[i] < ub[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(ua) - len(ub)
+}
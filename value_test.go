@@ -0,0 +1,155 @@
+package cf
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueOf(t *testing.T) {
+	date := time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	v, err := ValueOf(map[string]interface{}{
+		"int8":    int8(-1),
+		"uint16":  uint16(65535),
+		"uint64":  uint64(math.MaxUint64),
+		"float32": float32(1.5),
+		"date":    date,
+		"data":    [2]byte{1, 2},
+		"uid":     UID(7),
+	})
+	require.NoError(t, err)
+	require.Equal(t, Dict{
+		{"data", Data{1, 2}},
+		{"date", Date{date}},
+		{"float32", Real{1.5, 4}},
+		{"int8", Integer{Value: math.MaxUint64, Signed: true, Width: 1}},
+		{"uid", UID(7)},
+		{"uint16", Integer{Value: 65535, Width: 2}},
+		{"uint64", Integer{Value: math.MaxUint64, Width: 8}},
+	}, v)
+
+	_, err = ValueOf(nil)
+	require.IsType(t, &UnsupportedValueError{}, err)
+	_, err = ValueOf(make(chan int))
+	require.IsType(t, &UnsupportedTypeError{}, err)
+}
+
+func TestValueInterface(t *testing.T) {
+	require.Equal(t, int8(-1), Integer{Value: math.MaxUint64, Signed: true, Width: 1}.Interface())
+	require.Equal(t, uint32(5), Integer{Value: 5, Width: 4}.Interface())
+	require.Equal(t, int64(5), Integer{Value: 5, Width: 16}.Interface())
+	require.Equal(t, uint64(math.MaxUint64), Uint(math.MaxUint64).Interface())
+	require.Equal(t, float32(0.5), Real{0.5, 4}.Interface())
+	require.Equal(t, []interface{}(nil), Array{}.Interface())
+	require.Equal(t, map[string]interface{}{}, Dict{}.Interface())
+
+	date := time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	require.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 123000000, time.UTC).Local(), Date{date}.Interface())
+}
+
+func TestInteger(t *testing.T) {
+	i, ok := Int(-1).Int64()
+	require.True(t, ok)
+	require.Equal(t, int64(-1), i)
+	_, ok = Int(-1).Uint64()
+	require.False(t, ok)
+
+	_, ok = Uint(math.MaxUint64).Int64()
+	require.False(t, ok)
+	u, ok := Uint(math.MaxUint64).Uint64()
+	require.True(t, ok)
+	require.Equal(t, uint64(math.MaxUint64), u)
+	require.Equal(t, 16, Uint(math.MaxUint64).Width)
+	require.Equal(t, 8, Uint(1).Width)
+
+	for i, width := range map[Integer]int{
+		{Value: 300}:                                 8,
+		{Value: 127, Signed: true, Width: 1}:         1,
+		{Value: 1<<64 - 128, Signed: true, Width: 1}: 1,
+		{Value: 255, Width: 1}:                       2,
+		{Value: 1 << 40, Width: 8}:                   8,
+		{Value: math.MaxUint64, Width: 16}:           16,
+	} {
+		w, err := i.cfWidth()
+		require.NoError(t, err, i)
+		require.Equal(t, width, w, i)
+	}
+	for _, i := range []Integer{
+		{Value: 300, Width: 1},
+		{Value: 128, Signed: true, Width: 1},
+		{Value: 1<<64 - 129, Signed: true, Width: 1},
+		{Value: 1 << 16, Signed: true, Width: 2},
+		{Value: 1 << 32, Width: 4},
+	} {
+		_, err := i.cfWidth()
+		require.IsType(t, &OverflowError{}, err, i)
+	}
+}
+
+func TestDict(t *testing.T) {
+	d := Dict{}
+	d.Set("b", Int(1))
+	d.Set("a", Int(2))
+	d.Set("b", Int(3))
+	require.Equal(t, []string{"b", "a"}, d.Keys())
+
+	v, ok := d.Get("b")
+	require.True(t, ok)
+	require.Equal(t, Int(3), v)
+	_, ok = d.Get("c")
+	require.False(t, ok)
+
+	require.True(t, d.Delete("b"))
+	require.False(t, d.Delete("b"))
+	require.Equal(t, Dict{{"a", Int(2)}}, d)
+}
+
+func TestValueRoundTrip(t *testing.T) {
+	v := Dict{
+		{"z", Array{Real{0.25, 4}, Real{0.1, 8}, Uint(math.MaxUint64), Int(-3)}},
+		{"a", UID(12)},
+		{"m", Date{time.Date(2020, 1, 2, 3, 4, 5, 500000000, time.UTC)}},
+		{"d", Data{0xde, 0xad}},
+		{"s", String("ünïcödé")},
+		{"b", Bool(true)},
+	}
+
+	data, err := EncodeBinaryPlist(v)
+	require.NoError(t, err)
+	decoded, err := DecodeBinaryPlistValue(data)
+	require.NoError(t, err)
+	require.Equal(t, v.Keys(), decoded.(Dict).Keys())
+	requireValueEqual(t, v, decoded)
+
+	data, err = EncodeXMLPlist(Dict{{"uid", UID(12)}, {"big", Uint(math.MaxUint64)}})
+	require.NoError(t, err)
+	decoded, err = DecodeXMLPlistValue(data)
+	require.NoError(t, err)
+	require.Equal(t, Dict{{"uid", UID(12)}, {"big", Uint(math.MaxUint64)}}, decoded)
+}
+
+// requireValueEqual compares Values, comparing dates as instants
+func requireValueEqual(t *testing.T, expected, actual Value) {
+	switch e := expected.(type) {
+	case Date:
+		require.IsType(t, Date{}, actual)
+		require.True(t, e.Equal(actual.(Date).Time), "%v != %v", e, actual)
+	case Array:
+		require.IsType(t, Array{}, actual)
+		require.Len(t, actual, len(e))
+		for i := range e {
+			requireValueEqual(t, e[i], actual.(Array)[i])
+		}
+	case Dict:
+		require.IsType(t, Dict{}, actual)
+		require.Len(t, actual, len(e))
+		for i := range e {
+			require.Equal(t, e[i].Key, actual.(Dict)[i].Key)
+			requireValueEqual(t, e[i].Value, actual.(Dict)[i].Value)
+		}
+	default:
+		require.Equal(t, expected, actual)
+	}
+}
@@ -0,0 +1,179 @@
+package cf
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WatchInterval is how often watchers re-read domains on systems where changes
+// are not announced: always on darwin, where cfprefsd posts no notifications
+// for most domains, and on non-Linux systems without darwin.
+var WatchInterval = time.Second
+
+// PreferencesChange is a change of the value of a key. Old is nil if the key
+// was added, New if it was removed.
+type PreferencesChange struct {
+	Key string
+	Old interface{}
+	New interface{}
+}
+
+// MarshalJSON encodes the change as an object with "key", "old" and "new" keys,
+// leaving out nil values. Values are in the JSON form of property lists, like
+// the values of Change.
+func (c PreferencesChange) MarshalJSON() ([]byte, error) {
+	jc := struct {
+		Key string          `json:"key"`
+		Old json.RawMessage `json:"old,omitempty"`
+		New json.RawMessage `json:"new,omitempty"`
+	}{Key: c.Key}
+	var err error
+	if jc.Old, err = marshalJSONPlistValue(c.Old); err != nil {
+		return nil, err
+	}
+	if jc.New, err = marshalJSONPlistValue(c.New); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jc)
+}
+
+// PreferencesEvent holds the changes a Watcher saw in one update of a domain,
+// sorted by key.
+type PreferencesEvent struct {
+	Domain  Domain
+	Changes []PreferencesChange
+}
+
+// Watcher delivers the changes made to a preference domain by this or other
+// processes. Values are the ones PreferencesMulti returns, so they have the
+// same Go types as the values of Preferences.
+type Watcher struct {
+	// Events receives an event for every update of the domain that changed
+	// the value of a key
+	Events chan PreferencesEvent
+	// Errors receives the errors of watching and reading the domain. Watching
+	// continues after them.
+	Errors chan error
+
+	domain  Domain
+	values  map[string]interface{}
+	trigger chan struct{}
+	done    chan struct{}
+	stop    func()
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+// Watch starts watching the domain. The channels of the watcher have to be
+// drained until Close is called.
+//
+// On darwin, the domain is re-read every WatchInterval and when one of the
+// distributed notifications named in notifications is posted, such as
+// "com.apple.dock.prefchanged". Elsewhere, the file of the domain is watched
+// with inotify, or re-read every WatchInterval where inotify is not available,
+// and notifications are ignored.
+func Watch(d Domain, notifications ...string) (*Watcher, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	values, err := PreferencesMulti(nil, d.Application, d.User, d.Host)
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		Events:  make(chan PreferencesEvent),
+		Errors:  make(chan error),
+		domain:  d,
+		values:  values,
+		trigger: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	if w.stop, err = w.watch(notifications); err != nil {
+		return nil, err
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w, nil
+}
+
+// Close stops watching, and closes the channels of the watcher.
+func (w *Watcher) Close() error {
+	w.once.Do(func() {
+		close(w.done)
+		w.stop()
+		w.wg.Wait()
+		close(w.Events)
+		close(w.Errors)
+	})
+	return nil
+}
+
+// notify makes the watcher re-read the domain. It does not block, as updates
+// that are already pending cover the change.
+func (w *Watcher) notify() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// fail delivers an error unless the watcher is closed
+func (w *Watcher) fail(err error) {
+	select {
+	case w.Errors <- err:
+	case <-w.done:
+	}
+}
+
+func (w *Watcher) run() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.trigger:
+		case <-w.done:
+			return
+		}
+		if _, err := PreferencesSynchronize(w.domain.Application, w.domain.User, w.domain.Host); err != nil {
+			w.fail(err)
+			continue
+		}
+		values, err := PreferencesMulti(nil, w.domain.Application, w.domain.User, w.domain.Host)
+		if err != nil {
+			w.fail(err)
+			continue
+		}
+		changes := preferencesChanges(w.values, values)
+		w.values = values
+		if len(changes) == 0 {
+			continue
+		}
+		select {
+		case w.Events <- PreferencesEvent{w.domain, changes}:
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// preferencesChanges compares the values of two reads of a domain the way Diff
+// does, key by key
+func preferencesChanges(old, new map[string]interface{}) []PreferencesChange {
+	var changes []PreferencesChange
+	for key, v := range new {
+		patch, err := Diff(old[key], v)
+		if err != nil || len(patch) > 0 {
+			changes = append(changes, PreferencesChange{key, old[key], v})
+		}
+	}
+	for key, v := range old {
+		if _, ok := new[key]; !ok {
+			changes = append(changes, PreferencesChange{key, v, nil})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
@@ -0,0 +1,22 @@
+//go:build darwin
+// +build darwin
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <stdint.h>
+
+extern void gocfWatchNotify(uintptr_t handle);
+
+static void gocf_watchCallback(CFNotificationCenterRef center, void *observer, CFNotificationName name,
+                               const void *object, CFDictionaryRef userInfo) {
+    gocfWatchNotify((uintptr_t)observer);
+}
+
+void gocf_watchAddObserver(uintptr_t handle, CFStringRef name) {
+    CFNotificationCenterAddObserver(CFNotificationCenterGetDistributedCenter(), (const void *)handle,
+                                    gocf_watchCallback, name, NULL,
+                                    CFNotificationSuspensionBehaviorDeliverImmediately);
+}
+
+void gocf_watchRemoveObservers(uintptr_t handle) {
+    CFNotificationCenterRemoveEveryObserver(CFNotificationCenterGetDistributedCenter(), (const void *)handle);
+}
@@ -0,0 +1,94 @@
+//go:build darwin
+// +build darwin
+
+package cf
+
+// #import <CoreFoundation/CoreFoundation.h>
+// #include <stdint.h>
+// void gocf_watchAddObserver(uintptr_t handle, CFStringRef name);
+// void gocf_watchRemoveObservers(uintptr_t handle);
+import "C"
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// watchers maps the observer handles passed to CoreFoundation to watchers, as
+// Go pointers cannot be passed to C
+var watchers = struct {
+	sync.Mutex
+	next uintptr
+	m    map[uintptr]*Watcher
+}{m: map[uintptr]*Watcher{}}
+
+//export gocfWatchNotify
+func gocfWatchNotify(handle C.uintptr_t) {
+	watchers.Lock()
+	w := watchers.m[uintptr(handle)]
+	watchers.Unlock()
+	if w != nil {
+		w.notify()
+	}
+}
+
+// watch observes the notifications on a thread running a run loop, and
+// re-reads the domain whenever the run loop times out
+func (w *Watcher) watch(notifications []string) (func(), error) {
+	watchers.Lock()
+	watchers.next++
+	handle := watchers.next
+	watchers.m[handle] = w
+	watchers.Unlock()
+
+	started := make(chan error)
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer func() {
+			C.gocf_watchRemoveObservers(C.uintptr_t(handle))
+			watchers.Lock()
+			delete(watchers.m, handle)
+			watchers.Unlock()
+		}()
+
+		pool := &Pool{}
+		for _, name := range notifications {
+			name_, err := pool.String(name)
+			if err != nil {
+				pool.Release()
+				started <- errors.Wrap(err, "failed Watch")
+				return
+			}
+			C.gocf_watchAddObserver(C.uintptr_t(handle), C.CFStringRef(name_))
+		}
+		pool.Release()
+		started <- nil
+
+		for {
+			res := C.CFRunLoopRunInMode(C.kCFRunLoopDefaultMode, C.CFTimeInterval(WatchInterval.Seconds()), 0)
+			if res == C.kCFRunLoopRunFinished {
+				// nothing is scheduled on this thread's run loop
+				select {
+				case <-time.After(WatchInterval):
+				case <-w.done:
+					return
+				}
+			}
+			select {
+			case <-w.done:
+				return
+			default:
+			}
+			w.notify()
+		}
+	}()
+	if err := <-started; err != nil {
+		return nil, err
+	}
+	return func() {}, nil
+}
@@ -0,0 +1,66 @@
+//go:build linux
+// +build linux
+
+package cf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// watch watches the directory of the preference file, as files are replaced
+// by renaming and may not exist yet
+func (w *Watcher) watch(notifications []string) (func(), error) {
+	path, err := preferencesPath(w.domain.Application, w.domain.User, w.domain.Host)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed Watch")
+	}
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "failed Watch")
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, errors.Wrap(os.NewSyscallError("inotify_init1", err), "failed Watch")
+	}
+	const mask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+	if _, err := syscall.InotifyAddWatch(fd, dir, mask); err != nil {
+		syscall.Close(fd)
+		return nil, errors.Wrap(os.NewSyscallError("inotify_add_watch", err), "failed Watch")
+	}
+	// a non-blocking descriptor goes through the runtime poller, so that
+	// closing the file interrupts the read
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				select {
+				case <-w.done:
+				default:
+					w.fail(errors.Wrap(err, "failed Watch"))
+				}
+				return
+			}
+			for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+				ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				nameBytes := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+				if string(bytes.TrimRight(nameBytes, "\x00")) == name {
+					w.notify()
+				}
+				off += syscall.SizeofInotifyEvent + int(ev.Len)
+			}
+		}
+	}()
+	return func() { f.Close() }, nil
+}
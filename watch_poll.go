@@ -0,0 +1,26 @@
+//go:build !darwin && !linux
+// +build !darwin,!linux
+
+package cf
+
+import (
+	"time"
+)
+
+// watch re-reads the domain every WatchInterval
+func (w *Watcher) watch(notifications []string) (func(), error) {
+	ticker := time.NewTicker(WatchInterval)
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		for {
+			select {
+			case <-ticker.C:
+				w.notify()
+			case <-w.done:
+				return
+			}
+		}
+	}()
+	return ticker.Stop, nil
+}
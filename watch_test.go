@@ -0,0 +1,74 @@
+//go:build !darwin
+// +build !darwin
+
+package cf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func nextPreferencesEvent(t *testing.T, w *Watcher) PreferencesEvent {
+	select {
+	case ev := <-w.Events:
+		return ev
+	case err := <-w.Errors:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+	return PreferencesEvent{}
+}
+
+func TestWatch(t *testing.T) {
+	withPreferencesRoot(t)
+	d := UserDomain("com.example")
+	require.NoError(t, d.Set("a", "aval"))
+
+	w, err := Watch(d)
+	require.NoError(t, err)
+	defer w.Close()
+
+	require.NoError(t, d.SetMulti(map[string]interface{}{"a": "aval2", "b": []int{1}}))
+	ev := nextPreferencesEvent(t, w)
+	require.Equal(t, d, ev.Domain)
+	require.Equal(t, []PreferencesChange{
+		{"a", "aval", "aval2"},
+		{"b", nil, []interface{}{int64(1)}},
+	}, ev.Changes)
+
+	// rewriting the same values is not a change
+	require.NoError(t, d.Set("b", []int64{1}))
+	_, err = d.RemoveAll(true)
+	require.NoError(t, err)
+	ev = nextPreferencesEvent(t, w)
+	require.Equal(t, []PreferencesChange{
+		{"a", "aval2", nil},
+		{"b", []interface{}{int64(1)}, nil},
+	}, ev.Changes)
+
+	// other domains are not watched
+	require.NoError(t, GlobalDomain().Set("a", "x"))
+	require.NoError(t, d.Set("c", true))
+	ev = nextPreferencesEvent(t, w)
+	require.Equal(t, []PreferencesChange{{"c", nil, true}}, ev.Changes)
+
+	require.NoError(t, w.Close())
+	_, ok := <-w.Events
+	require.False(t, ok)
+}
+
+func TestPreferencesChanges(t *testing.T) {
+	require.Empty(t, preferencesChanges(
+		map[string]interface{}{"a": int64(1), "b": []interface{}{"x"}},
+		map[string]interface{}{"a": int8(1), "b": []interface{}{"x"}}))
+	require.Equal(t, []PreferencesChange{
+		{"a", int64(1), 1.0},
+		{"b", nil, "y"},
+		{"c", "z", nil},
+	}, preferencesChanges(
+		map[string]interface{}{"a": int64(1), "c": "z"},
+		map[string]interface{}{"a": 1.0, "b": "y"}))
+}
@@ -0,0 +1,72 @@
+package cf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic replaces the file at path with data by renaming a temporary
+// file over it, creating the directory if needed. An existing file keeps its
+// permissions, and its owner when running as root. A new file gets perm, and
+// when running as root, it and the directories created for it get the owner of
+// the nearest directory that existed, so that files written into the home
+// directories of other users belong to them.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	owner := dir
+	var created []string
+	for {
+		if _, err := os.Stat(owner); err == nil || !os.IsNotExist(err) {
+			break
+		}
+		created = append(created, owner)
+		parent := filepath.Dir(owner)
+		if parent == owner {
+			break
+		}
+		owner = parent
+	}
+	if fi, err := os.Stat(path); err == nil {
+		perm = fi.Mode().Perm()
+		owner = path
+	}
+	uid, gid, chown := -1, -1, false
+	if os.Geteuid() == 0 {
+		if fi, err := os.Stat(owner); err == nil {
+			uid, gid, chown = fileOwner(fi)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if chown {
+		for _, d := range created {
+			if err := os.Chown(d, uid, gid); err != nil {
+				return err
+			}
+		}
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err == nil && chown {
+		err = os.Chown(tmp.Name(), uid, gid)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package cf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cf-write")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "a", "b", "file")
+	require.NoError(t, writeFileAtomic(path, []byte("one"), 0600))
+	require.NoError(t, os.Chmod(path, 0644))
+	require.NoError(t, writeFileAtomic(path, []byte("two"), 0600))
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "two", string(data))
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0644), fi.Mode().Perm())
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+}
+
+func TestWriteFileAtomicOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing owners needs root")
+	}
+	dir, err := ioutil.TempDir("", "cf-write")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fi, err := os.Stat(dir)
+	require.NoError(t, err)
+	if _, _, ok := fileOwner(fi); !ok {
+		t.Skip("files have no numeric owners")
+	}
+
+	// a home directory of another user without Library/Preferences
+	home := filepath.Join(dir, "home")
+	require.NoError(t, os.Mkdir(home, 0755))
+	require.NoError(t, os.Chown(home, 1234, 5678))
+	path := filepath.Join(home, filepath.FromSlash(DockFilePath))
+	require.NoError(t, writeFileAtomic(path, []byte("data"), 0600))
+
+	for _, p := range []string{path, filepath.Dir(path), filepath.Dir(filepath.Dir(path))} {
+		fi, err := os.Stat(p)
+		require.NoError(t, err)
+		uid, gid, _ := fileOwner(fi)
+		require.Equal(t, []int{1234, 5678}, []int{uid, gid}, p)
+	}
+	fi, err = os.Stat(dir)
+	require.NoError(t, err)
+	uid, _, _ := fileOwner(fi)
+	require.Equal(t, 0, uid)
+}
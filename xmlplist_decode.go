@@ -0,0 +1,265 @@
+package cf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const xmlPlistDateFormat = "2006-01-02T15:04:05Z"
+
+// InvalidXMLPlistError is returned when XML property list data is malformed.
+type InvalidXMLPlistError struct {
+	Line   int
+	Reason string
+}
+
+func (e *InvalidXMLPlistError) Error() string {
+	return fmt.Sprintf("plist: invalid XML plist at line %d: %s", e.Line, e.Reason)
+}
+
+type xmlPlistDecoder struct {
+	d    *xml.Decoder
+	data []byte
+}
+
+// CoreFoundation writes a UID as a dictionary with this single key
+const xmlPlistUIDKey = "CF$UID"
+
+// DecodeXMLPlist decodes an XML property list (plist-1.0 DTD) into Go values of
+// the types typeRef.Goize produces: string, int64, float64, bool, []byte,
+// time.Time (local), []interface{} (nil when empty) and map[string]interface{},
+// plus uint64 for integers above the int64 range and UID.
+func DecodeXMLPlist(data []byte) (interface{}, error) {
+	v, err := DecodeXMLPlistValue(data)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// DecodeXMLPlistValue decodes an XML property list into a Value, keeping
+// dictionary key order.
+func DecodeXMLPlistValue(data []byte) (Value, error) {
+	p := &xmlPlistDecoder{d: xml.NewDecoder(bytes.NewReader(data)), data: data}
+	// The DTD does not declare any entities beyond the XML ones
+	p.d.Strict = true
+
+	start, err := p.nextElement()
+	if err != nil {
+		return nil, err
+	}
+	if start.Name.Local == "plist" {
+		if start, err = p.nextElement(); err != nil {
+			return nil, err
+		}
+	}
+	return p.object(start)
+}
+
+func (p *xmlPlistDecoder) fail(format string, args ...interface{}) error {
+	offset := int(p.d.InputOffset())
+	if offset > len(p.data) {
+		offset = len(p.data)
+	}
+	line := 1 + bytes.Count(p.data[:offset], []byte("\n"))
+	return &InvalidXMLPlistError{Line: line, Reason: fmt.Sprintf(format, args...)}
+}
+
+func (p *xmlPlistDecoder) token() (xml.Token, error) {
+	tok, err := p.d.Token()
+	if err == io.EOF {
+		return nil, p.fail("unexpected end of document")
+	}
+	if err != nil {
+		return nil, p.fail("%s", err)
+	}
+	return tok, nil
+}
+
+// nextElement skips to the next start element. It returns a nil element if
+// the enclosing element ends first.
+func (p *xmlPlistDecoder) nextElement() (*xml.StartElement, error) {
+	for {
+		tok, err := p.token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			return &t, nil
+		case xml.EndElement:
+			return nil, nil
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) != 0 {
+				return nil, p.fail("unexpected text %q", string(t))
+			}
+		}
+	}
+}
+
+// text returns the character data of the current element up to its end tag
+func (p *xmlPlistDecoder) text(name string) (string, error) {
+	var sb strings.Builder
+	for {
+		tok, err := p.token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			sb.Write(t)
+		case xml.EndElement:
+			return sb.String(), nil
+		case xml.StartElement:
+			return "", p.fail("unexpected <%s> in <%s>", t.Name.Local, name)
+		}
+	}
+}
+
+func (p *xmlPlistDecoder) object(start *xml.StartElement) (Value, error) {
+	if start == nil {
+		return nil, p.fail("missing value")
+	}
+	name := start.Name.Local
+	switch name {
+	case "dict":
+		return p.dict()
+	case "array":
+		return p.array()
+	case "true", "false":
+		if _, err := p.text(name); err != nil {
+			return nil, err
+		}
+		return Bool(name == "true"), nil
+	}
+
+	s, err := p.text(name)
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "string":
+		return String(s), nil
+	case "integer":
+		i, err := parseXMLPlistInteger(strings.TrimSpace(s))
+		if err != nil {
+			return nil, p.fail("invalid integer %q", s)
+		}
+		return i, nil
+	case "real":
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, p.fail("invalid real %q", s)
+		}
+		return Real{f, 8}, nil
+	case "date":
+		t, err := time.Parse(xmlPlistDateFormat, strings.TrimSpace(s))
+		if err != nil {
+			return nil, p.fail("invalid date %q", s)
+		}
+		return Date{t}, nil
+	case "data":
+		data, err := base64.StdEncoding.DecodeString(strings.Map(dropSpace, s))
+		if err != nil {
+			return nil, p.fail("invalid base64 data")
+		}
+		return Data(data), nil
+	}
+	return nil, p.fail("unknown element <%s>", name)
+}
+
+func (p *xmlPlistDecoder) array() (Array, error) {
+	out := Array{}
+	for {
+		start, err := p.nextElement()
+		if err != nil {
+			return nil, err
+		}
+		if start == nil {
+			return out, nil
+		}
+		v, err := p.object(start)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+}
+
+func (p *xmlPlistDecoder) dict() (Value, error) {
+	out := Dict{}
+	for {
+		start, err := p.nextElement()
+		if err != nil {
+			return nil, err
+		}
+		if start == nil {
+			break
+		}
+		if start.Name.Local != "key" {
+			return nil, p.fail("expected <key>, found <%s>", start.Name.Local)
+		}
+		key, err := p.text("key")
+		if err != nil {
+			return nil, err
+		}
+		start, err = p.nextElement()
+		if err != nil {
+			return nil, err
+		}
+		v, err := p.object(start)
+		if err != nil {
+			return nil, err
+		}
+		out.Set(key, v)
+	}
+
+	if len(out) == 1 && out[0].Key == xmlPlistUIDKey {
+		if i, ok := out[0].Value.(Integer); ok {
+			if u, ok := i.Uint64(); ok {
+				return UID(u), nil
+			}
+		}
+	}
+	return out, nil
+}
+
+// parseXMLPlistInteger parses decimal and 0x-prefixed hexadecimal integers
+// in the int64 and uint64 ranges
+func parseXMLPlistInteger(s string) (Integer, error) {
+	neg := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	base := 10
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		digits, base = digits[2:], 16
+	}
+	u, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		return Integer{}, err
+	}
+	if !neg {
+		if u > math.MaxInt64 {
+			return Uint(u), nil
+		}
+		return Int(int64(u)), nil
+	}
+	if u > 1<<63 {
+		return Integer{}, strconv.ErrRange
+	}
+	return Int(-int64(u)), nil
+}
+
+func dropSpace(r rune) rune {
+	switch r {
+	case ' ', '\t', '\n', '\r':
+		return -1
+	}
+	return r
+}
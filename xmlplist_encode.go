@@ -0,0 +1,149 @@
+package cf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const xmlPlistHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+`
+
+const xmlPlistFooter = "</plist>\n"
+
+// Base64 lines in <data> are at most this long, indentation (counted as 8
+// columns per tab, and at most 8 tabs) included
+const xmlPlistDataLineLength = 76
+
+// EncodeXMLPlist encodes v as an XML property list, in the layout
+// `plutil -convert xml1` produces: tab indentation, sorted dictionary keys (or
+// Dict order), UTC dates with second precision and wrapped base64 data.
+//
+// v may be any value Pool.Object accepts, and may contain Values.
+func EncodeXMLPlist(v interface{}) ([]byte, error) {
+	val, err := objectValue(v)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBufferString(xmlPlistHeader)
+	writeXMLPlistValue(buf, val, 0)
+	buf.WriteString(xmlPlistFooter)
+	return buf.Bytes(), nil
+}
+
+func writeXMLPlistValue(buf *bytes.Buffer, val Value, indent int) {
+	tabs := strings.Repeat("\t", indent)
+	switch v := val.(type) {
+	case Bool:
+		if v {
+			buf.WriteString(tabs + "<true/>\n")
+		} else {
+			buf.WriteString(tabs + "<false/>\n")
+		}
+	case Integer:
+		buf.WriteString(tabs + "<integer>" + formatInteger(v) + "</integer>\n")
+	case Real:
+		buf.WriteString(tabs + "<real>" + formatXMLPlistReal(v.Value) + "</real>\n")
+	case Date:
+		buf.WriteString(tabs + "<date>" + v.UTC().Format(xmlPlistDateFormat) + "</date>\n")
+	case Data:
+		buf.WriteString(tabs + "<data>\n")
+		writeXMLPlistData(buf, v, indent)
+		buf.WriteString(tabs + "</data>\n")
+	case String:
+		buf.WriteString(tabs + "<string>")
+		writeXMLPlistEscaped(buf, string(v))
+		buf.WriteString("</string>\n")
+	case UID:
+		// CoreFoundation writes UIDs as single-key dictionaries
+		buf.WriteString(tabs + "<dict>\n")
+		buf.WriteString(tabs + "\t<key>" + xmlPlistUIDKey + "</key>\n")
+		buf.WriteString(tabs + "\t<integer>" + strconv.FormatUint(uint64(v), 10) + "</integer>\n")
+		buf.WriteString(tabs + "</dict>\n")
+	case Array:
+		if len(v) == 0 {
+			buf.WriteString(tabs + "<array/>\n")
+			return
+		}
+		buf.WriteString(tabs + "<array>\n")
+		for _, elem := range v {
+			writeXMLPlistValue(buf, elem, indent+1)
+		}
+		buf.WriteString(tabs + "</array>\n")
+	case Dict:
+		if len(v) == 0 {
+			buf.WriteString(tabs + "<dict/>\n")
+			return
+		}
+		buf.WriteString(tabs + "<dict>\n")
+		for _, e := range v {
+			buf.WriteString(tabs + "\t<key>")
+			writeXMLPlistEscaped(buf, e.Key)
+			buf.WriteString("</key>\n")
+			writeXMLPlistValue(buf, e.Value, indent+1)
+		}
+		buf.WriteString(tabs + "</dict>\n")
+	default:
+		panic("plist: unexpected Value type")
+	}
+}
+
+func formatInteger(i Integer) string {
+	if i.Signed {
+		return strconv.FormatInt(int64(i.Value), 10)
+	}
+	return strconv.FormatUint(i.Value, 10)
+}
+
+// formatXMLPlistReal formats a real the way CFNumber's formatting description does
+func formatXMLPlistReal(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "+infinity"
+	case math.IsInf(f, -1):
+		return "-infinity"
+	case f == 0 && math.Signbit(f):
+		return "-0.0"
+	case f == 0:
+		return "0.0"
+	}
+	return strconv.FormatFloat(f, 'g', 17, 64)
+}
+
+func writeXMLPlistData(buf *bytes.Buffer, data []byte, indent int) {
+	if indent > 8 {
+		indent = 8
+	}
+	tabs := strings.Repeat("\t", indent)
+	lineLength := xmlPlistDataLineLength - 8*indent
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := lineLength
+		if n > len(encoded) {
+			n = len(encoded)
+		}
+		buf.WriteString(tabs + encoded[:n] + "\n")
+		encoded = encoded[n:]
+	}
+}
+
+func writeXMLPlistEscaped(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '<':
+			buf.WriteString("&lt;")
+		case '>':
+			buf.WriteString("&gt;")
+		case '&':
+			buf.WriteString("&amp;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
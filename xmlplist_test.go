@@ -0,0 +1,128 @@
+package cf
+
+import (
+	"math"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const xmlPlistFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>data</key>
+	<data>
+	AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJygpKissLS4vMDEy
+	MzQ1Njc4OTo7PD0+P0BBQkNERUZHSElKS0xNTk9QUVJTVFVWV1hZWltcXV5fYGFiY2Rl
+	ZmdoaWprbG1ub3BxcnN0dXZ3eHl6e3x9fn8=
+	</data>
+	<key>date</key>
+	<date>2019-03-04T05:06:07Z</date>
+	<key>empty</key>
+	<array/>
+	<key>escaped</key>
+	<string>&lt;a href="x"&gt;&amp;&lt;/a&gt;</string>
+	<key>list</key>
+	<array>
+		<integer>-7</integer>
+		<real>0.10000000000000001</real>
+		<real>0.0</real>
+		<real>+infinity</real>
+		<true/>
+		<false/>
+		<dict/>
+	</array>
+	<key>name</key>
+	<string>héllo ☃</string>
+</dict>
+</plist>
+`
+
+func xmlPlistFixtureData() []byte {
+	data := make([]byte, 128)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	return data
+}
+
+func TestEncodeXMLPlist(t *testing.T) {
+	out, err := EncodeXMLPlist(map[string]interface{}{
+		"data":    xmlPlistFixtureData(),
+		"date":    time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC),
+		"empty":   []int{},
+		"escaped": `<a href="x">&</a>`,
+		"list":    []interface{}{-7, 0.1, 0.0, math.Inf(1), true, false, map[string]string{}},
+		"name":    "héllo ☃",
+	})
+	require.NoError(t, err)
+	require.Equal(t, xmlPlistFixture, string(out))
+}
+
+func TestDecodeXMLPlist(t *testing.T) {
+	v, err := DecodeXMLPlist([]byte(xmlPlistFixture))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"data":    xmlPlistFixtureData(),
+		"date":    time.Unix(1551675967, 0),
+		"empty":   []interface{}(nil),
+		"escaped": `<a href="x">&</a>`,
+		"list":    []interface{}{int64(-7), 0.1, 0.0, math.Inf(1), true, false, map[string]interface{}{}},
+		"name":    "héllo ☃",
+	}, v)
+}
+
+func TestDecodeXMLPlistLenient(t *testing.T) {
+	v, err := DecodeXMLPlist([]byte(`<array><integer> 0x10 </integer><string>&#x263A;<![CDATA[<x>]]></string>` +
+		`<data>AA EC</data></array>`))
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{int64(16), "☺<x>", []byte{0, 1, 2}}, v)
+}
+
+func TestDecodeXMLPlistInvalid(t *testing.T) {
+	for _, doc := range []string{
+		``,
+		`<plist><dict><string>a</string></dict></plist>`,
+		`<plist><dict><key>a</key></dict></plist>`,
+		`<plist><integer>x</integer></plist>`,
+		`<plist><array><foo/></array></plist>`,
+		`<plist><string>&bogus;</string></plist>`,
+	} {
+		_, err := DecodeXMLPlist([]byte(doc))
+		require.IsType(t, &InvalidXMLPlistError{}, err, doc)
+	}
+}
+
+func TestXMLPlistArbitrary(t *testing.T) {
+	// XML dates only keep whole seconds
+	truncate := func(v interface{}) interface{} {
+		if t, ok := v.(time.Time); ok {
+			return time.Unix(t.Unix(), 0)
+		}
+		return v
+	}
+	f := func(arb Arbitrary) interface{} { a, _ := standardize(truncate(arb.Value)); return a }
+	g := func(arb Arbitrary) interface{} {
+		data, err := EncodeXMLPlist(arb.Value)
+		require.NoError(t, err)
+		val, err := DecodeXMLPlist(data)
+		require.NoError(t, err, string(data))
+		a, _ := standardize(val)
+		return a
+	}
+	if err := quick.CheckEqual(f, g, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestXMLPlistNegativeZero(t *testing.T) {
+	data, err := EncodeXMLPlist(math.Copysign(0, -1))
+	require.NoError(t, err)
+	require.Contains(t, string(data), "<real>-0.0</real>")
+	v, err := DecodeXMLPlist(data)
+	require.NoError(t, err)
+	require.True(t, math.Signbit(v.(float64)))
+}